@@ -2,35 +2,89 @@ package aws
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"time"
 
+	"github.com/aws-powertools/actions/layer-balancer/config"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/retry"
-	"github.com/aws/aws-sdk-go-v2/config"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
-func NewDefaultClientConfig(ctx context.Context, region string) *ClientConfig {
+func NewDefaultClientConfig(ctx context.Context, region string) (*ClientConfig, error) {
+	return newDefaultClientConfig(ctx, region, config.Endpoints{})
+}
+
+func newDefaultClientConfig(ctx context.Context, region string, endpoints config.Endpoints) (*ClientConfig, error) {
 	clientCfg := NewClientConfig(ctx)
-	clientCfg.Default(region)
+	clientCfg.Endpoints = endpoints
+	if err := clientCfg.Default(region); err != nil {
+		return nil, fmt.Errorf("loading config for %s: %w", region, err)
+	}
 
-	return clientCfg
+	return clientCfg, nil
 }
 
-func NewClientConfigWithRole(ctx context.Context, region string, role string) *ClientConfig {
-	clientCfg := NewClientConfig(ctx)
-	clientCfg.Default(region)
-	clientCfg.AssumeRole(region, role)
+// NewClientConfigWithRole loads the default config and assumes role with it,
+// an empty role keeps the default credentials. Credentials are only requested
+// on the first call, see CallerIdentity.
+func NewClientConfigWithRole(ctx context.Context, region string, role string, optFns ...func(*stscreds.AssumeRoleOptions)) (*ClientConfig, error) {
+	clientCfg, err := newDefaultClientConfig(ctx, region, config.Endpoints{})
+	if err != nil {
+		return nil, err
+	}
+
+	if role != "" {
+		if err := clientCfg.AssumeRole(region, role, optFns...); err != nil {
+			return nil, fmt.Errorf("assuming %s in %s: %w", role, region, err)
+		}
+	}
 
-	return clientCfg
+	return clientCfg, nil
+}
+
+// NewClientConfigWithRoles assumes every role in order, each one with the
+// credentials of the one before it, e.g. a hub role and then the role it is
+// trusted by. The final role is assumed with every option, the roles before it
+// only with the session name and duration. No roles keeps the default
+// credentials, see ChainIdentity. The clients of the config, STS included, are
+// pointed at endpoints.
+func NewClientConfigWithRoles(ctx context.Context, region string, endpoints config.Endpoints, roles []string, options config.RoleOptions) (*ClientConfig, error) {
+	clientCfg, err := newDefaultClientConfig(ctx, region, endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, role := range roles {
+		optFn := WithChainedRoleOptions(options)
+		if i == len(roles)-1 {
+			optFn = WithRoleOptions(options)
+		}
+
+		if err := clientCfg.AssumeRole(region, role, optFn); err != nil {
+			if len(roles) > 1 {
+				return nil, fmt.Errorf("assuming %s in %s, hop %d of %d: %w", role, region, i+1, len(roles), err)
+			}
+			return nil, fmt.Errorf("assuming %s in %s: %w", role, region, err)
+		}
+	}
+
+	return clientCfg, nil
 }
 
 func NewClientConfig(ctx context.Context) *ClientConfig {
-	return &ClientConfig{
+	cc := &ClientConfig{
 		ctx:            ctx,
-		ConfigLoaderFn: config.LoadDefaultConfig,
+		ConfigLoaderFn: awsConfig.LoadDefaultConfig,
 	}
+	cc.STSClientFn = cc.newSTSClient
+
+	return cc
 }
 
 type ClientConfig struct {
@@ -38,19 +92,30 @@ type ClientConfig struct {
 	config aws.Config
 
 	ConfigLoaderFn ConfigLoaderFn
+	STSClientFn    STSClientFn
+
+	// Endpoints are set before Default, they apply to every client of the config.
+	Endpoints config.Endpoints
 
 	Region string
+	// Role is the last assumed role, empty for the default credentials.
+	Role string
+	// Chain holds every assumed role in order, ending with Role.
+	Chain []string
+
+	// hops are the configs of the roles in Chain
+	hops []aws.Config
 }
 
 func (cc *ClientConfig) Default(region string) error {
 	cc.Region = region
 
-	config, err := cc.ConfigLoaderFn(cc.ctx, config.WithRegion(region), config.WithRetryer(func() aws.Retryer {
-		retrier := retry.AddWithMaxAttempts(retry.NewStandard(), 5)
-		retrier = retry.AddWithMaxBackoffDelay(retrier, time.Second*1)
+	opts, err := cc.loadOptions(region)
+	if err != nil {
+		return err
+	}
 
-		return retrier
-	}))
+	config, err := cc.ConfigLoaderFn(cc.ctx, opts...)
 	if err != nil {
 		return err
 	}
@@ -60,32 +125,60 @@ func (cc *ClientConfig) Default(region string) error {
 	return nil
 }
 
-func (cc *ClientConfig) AssumeRole(region string, role string) error {
-	stsClient := sts.NewFromConfig(cc.config)
+// AssumeRole assumes role with the current credentials, calling it again
+// chains the next role on top of this one.
+func (cc *ClientConfig) AssumeRole(region string, role string, optFns ...func(*stscreds.AssumeRoleOptions)) error {
+	stsClient := sts.NewFromConfig(cc.config, cc.stsOptions)
 
-	newConfig, err := cc.ConfigLoaderFn(cc.ctx, config.WithRegion(region),
-		config.WithRetryer(func() aws.Retryer {
-			retrier := retry.AddWithMaxAttempts(retry.NewStandard(), 5)
-			retrier = retry.AddWithMaxBackoffDelay(retrier, time.Second*1)
+	opts, err := cc.loadOptions(region)
+	if err != nil {
+		return err
+	}
 
-			return retrier
-		}),
-		config.WithCredentialsProvider(aws.NewCredentialsCache(
-			stscreds.NewAssumeRoleProvider(stsClient, role),
+	newConfig, err := cc.ConfigLoaderFn(cc.ctx, append(opts,
+		awsConfig.WithCredentialsProvider(aws.NewCredentialsCache(
+			stscreds.NewAssumeRoleProvider(stsClient, role, optFns...),
 		)),
-	)
+	)...)
 
 	if err != nil {
 		return err
 	}
 
 	cc.config = newConfig
+	cc.Role = role
+	cc.Chain = append(cc.Chain, role)
+	cc.hops = append(cc.hops, newConfig)
 
 	return nil
 }
 
+func (cc *ClientConfig) loadOptions(region string) ([]func(*awsConfig.LoadOptions) error, error) {
+	opts := []func(*awsConfig.LoadOptions) error{
+		awsConfig.WithRegion(region),
+		awsConfig.WithRetryer(func() aws.Retryer {
+			retrier := retry.AddWithMaxAttempts(retry.NewStandard(), 5)
+			retrier = retry.AddWithMaxBackoffDelay(retrier, time.Second*1)
+
+			return retrier
+		}),
+	}
+
+	tlsCfg, err := TLSConfig(cc.Endpoints)
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg != nil {
+		opts = append(opts, awsConfig.WithHTTPClient(awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+			tr.TLSClientConfig = tlsCfg
+		})))
+	}
+
+	return opts, nil
+}
+
 func (cc *ClientConfig) SDKConfig() aws.Config {
 	return cc.config
 }
 
-type ConfigLoaderFn func(ctx context.Context, optFns ...func(*config.LoadOptions) error) (aws.Config, error)
+type ConfigLoaderFn func(ctx context.Context, optFns ...func(*awsConfig.LoadOptions) error) (aws.Config, error)
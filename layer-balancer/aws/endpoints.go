@@ -0,0 +1,98 @@
+package aws
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// LambdaClient is a Lambda client of the config, using the Lambda endpoint when set.
+func (cc *ClientConfig) LambdaClient() *lambda.Client {
+	return lambda.NewFromConfig(cc.config, func(o *lambda.Options) {
+		o.BaseEndpoint = endpoint(cc.Endpoints.Lambda)
+	})
+}
+
+// S3Client is an S3 client of the config. With an S3 endpoint, buckets are
+// addressed by path as emulators rarely resolve bucket subdomains.
+func (cc *ClientConfig) S3Client() *s3.Client {
+	return s3.NewFromConfig(cc.config, func(o *s3.Options) {
+		if e := endpoint(cc.Endpoints.S3); e != nil {
+			o.BaseEndpoint = e
+			o.UsePathStyle = true
+		}
+	})
+}
+
+func (cc *ClientConfig) SSMClient() *ssm.Client {
+	return ssm.NewFromConfig(cc.config, func(o *ssm.Options) {
+		o.BaseEndpoint = endpoint(cc.Endpoints.SSM)
+	})
+}
+
+func (cc *ClientConfig) stsOptions(o *sts.Options) {
+	o.BaseEndpoint = endpoint(cc.Endpoints.STS)
+}
+
+// TLSConfig trusts the CA bundle of e on top of the system roots, or skips
+// verification. It is nil when e keeps the default TLS settings.
+func TLSConfig(e config.Endpoints) (*tls.Config, error) {
+	if e.CABundle == "" && !e.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: e.InsecureSkipVerify,
+	}
+
+	if e.CABundle != "" {
+		pem, err := os.ReadFile(e.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA bundle: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s has no PEM certificates", e.CABundle)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	return tlsCfg, nil
+}
+
+// HTTPClient is an http.Client with the TLS settings of e, for requests made
+// outside of the SDK such as package downloads.
+func HTTPClient(e config.Endpoints) (*http.Client, error) {
+	tlsCfg, err := TLSConfig(e)
+	if err != nil || tlsCfg == nil {
+		return &http.Client{}, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsCfg
+
+	return &http.Client{Transport: transport}, nil
+}
+
+func endpoint(url string) *string {
+	if url == "" {
+		return nil
+	}
+
+	return aws.String(url)
+}
@@ -0,0 +1,101 @@
+package aws_test
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/aws"
+	"github.com/aws-powertools/actions/layer-balancer/config"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+const callerIdentity = `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetCallerIdentityResult>
+    <Arn>arn:aws:iam::123456789012:user/emulator</Arn>
+    <UserId>AIDAEMULATOR</UserId>
+    <Account>123456789012</Account>
+  </GetCallerIdentityResult>
+  <ResponseMetadata><RequestId>1</RequestId></ResponseMetadata>
+</GetCallerIdentityResponse>`
+
+func TestEndpoints(t *testing.T) {
+	// keep the shared config and credentials of the machine out of the test
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEMULATOR")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	sts := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/xml")
+		rw.Write([]byte(callerIdentity))
+	}))
+	defer sts.Close()
+
+	lambdaServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/2018-10-31/layers/AWSLambdaPowertoolsPythonV3/versions" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"LayerVersions": [{"Version": 1, "LayerVersionArn": "arn:aws:lambda:eu-west-1:123456789012:layer:AWSLambdaPowertoolsPythonV3:1"}]}`))
+	}))
+	defer lambdaServer.Close()
+
+	t.Run("Endpoints CA bundle", func(t *testing.T) {
+		bundle := filepath.Join(t.TempDir(), "ca.pem")
+		if err := os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: sts.Certificate().Raw}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := aws.NewClientConfigWithRoles(context.TODO(), "eu-west-1", config.Endpoints{
+			Lambda:   lambdaServer.URL,
+			STS:      sts.URL,
+			CABundle: bundle,
+		}, nil, config.RoleOptions{})
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		identity, err := cfg.CallerIdentity(context.TODO())
+		if err != nil || identity.Account != "123456789012" {
+			t.Fatalf("expected the emulator identity, got: %+v, %v", identity, err)
+		}
+
+		out, err := cfg.LambdaClient().ListLayerVersions(context.TODO(), &lambda.ListLayerVersionsInput{
+			LayerName: awsSDK.String("AWSLambdaPowertoolsPythonV3"),
+		})
+		if err != nil || len(out.LayerVersions) != 1 {
+			t.Errorf("expected the emulator layer versions, got: %+v, %v", out, err)
+		}
+	})
+
+	t.Run("Endpoints TLS verification", func(t *testing.T) {
+		cfg, err := aws.NewClientConfigWithRoles(context.TODO(), "eu-west-1", config.Endpoints{STS: sts.URL}, nil, config.RoleOptions{})
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		// the failure is retried like any connection error, so it takes a few seconds
+		if _, err := cfg.CallerIdentity(context.TODO()); err == nil || !strings.Contains(err.Error(), "certificate") {
+			t.Errorf("expected an unknown certificate authority to fail, got: %v", err)
+		}
+
+		cfg, err = aws.NewClientConfigWithRoles(context.TODO(), "eu-west-1", config.Endpoints{STS: sts.URL, InsecureSkipVerify: true}, nil, config.RoleOptions{})
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		if _, err := cfg.CallerIdentity(context.TODO()); err != nil {
+			t.Errorf("expected verification to be skipped: %v", err)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Identity is who the credentials of a ClientConfig resolve to.
+type Identity struct {
+	Account string
+	Arn     string
+}
+
+type STSClient interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+type STSClientFn func(cfg aws.Config) STSClient
+
+func (cc *ClientConfig) newSTSClient(cfg aws.Config) STSClient {
+	return sts.NewFromConfig(cfg, cc.stsOptions)
+}
+
+// CallerIdentity calls sts:GetCallerIdentity, which resolves the credentials
+// and assumes the role of the config without touching any other service.
+func (cc *ClientConfig) CallerIdentity(ctx context.Context) (*Identity, error) {
+	out, err := cc.STSClientFn(cc.config).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{
+		Account: aws.ToString(out.Account),
+		Arn:     aws.ToString(out.Arn),
+	}, nil
+}
+
+// ChainIdentity resolves the identity of every hop of Chain in order, so a
+// failure names the hop that couldn't be assumed rather than only the last
+// role. It is CallerIdentity when at most one role is assumed.
+func (cc *ClientConfig) ChainIdentity(ctx context.Context) (*Identity, error) {
+	if len(cc.hops) < 2 {
+		return cc.CallerIdentity(ctx)
+	}
+
+	var identity *Identity
+	for i, hop := range cc.hops {
+		out, err := cc.STSClientFn(hop).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+		if err != nil {
+			return nil, fmt.Errorf("hop %d of %d, %s: %w", i+1, len(cc.hops), cc.Chain[i], err)
+		}
+
+		identity = &Identity{
+			Account: aws.ToString(out.Account),
+			Arn:     aws.ToString(out.Arn),
+		}
+	}
+
+	return identity, nil
+}
+
+// Check is a config to resolve in a preflight, Name says what it is used for.
+type Check struct {
+	Name   string
+	Config *ClientConfig
+}
+
+type CheckResult struct {
+	Name     string
+	Region   string
+	Identity *Identity
+	Err      error
+}
+
+// Preflight resolves the identity of every check at once, every hop of a
+// role chain is resolved in turn. The results are in
+// the order of checks, the error names every check that failed.
+func Preflight(ctx context.Context, checks []Check) ([]CheckResult, error) {
+	results := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			results[i] = CheckResult{Name: check.Name, Region: check.Config.Region}
+			results[i].Identity, results[i].Err = check.Config.ChainIdentity(ctx)
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	for i, r := range results {
+		if r.Err == nil {
+			continue
+		}
+
+		if role := checks[i].Config.Role; role != "" {
+			errs = append(errs, fmt.Errorf("%s in %s as %s: %w", r.Name, r.Region, role, r.Err))
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s in %s: %w", r.Name, r.Region, r.Err))
+	}
+
+	return results, errors.Join(errs...)
+}
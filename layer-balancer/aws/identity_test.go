@@ -0,0 +1,132 @@
+package aws_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/aws"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+type FakeSTSClient struct {
+	GetCallerIdentityFn func(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+func (c *FakeSTSClient) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return c.GetCallerIdentityFn(ctx, params, optFns...)
+}
+
+func TestPreflight(t *testing.T) {
+	newConfig := func(region string, role string, err error) *aws.ClientConfig {
+		cfg := aws.NewClientConfig(context.TODO())
+		cfg.Region = region
+		cfg.Role = role
+		cfg.STSClientFn = func(awsSDK.Config) aws.STSClient {
+			return &FakeSTSClient{
+				GetCallerIdentityFn: func(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+					if err != nil {
+						return nil, err
+					}
+
+					return &sts.GetCallerIdentityOutput{
+						Account: awsSDK.String("012345678912"),
+						Arn:     awsSDK.String("arn:aws:sts::012345678912:assumed-role/Balance/session"),
+					}, nil
+				},
+			}
+		}
+
+		return cfg
+	}
+
+	t.Run("Preflight", func(t *testing.T) {
+		results, err := aws.Preflight(context.TODO(), []aws.Check{
+			{Name: "read", Config: newConfig("us-east-1", "", nil)},
+			{Name: "write", Config: newConfig("eu-west-1", "arn:aws:iam::012345678912:role/Balance", nil)},
+		})
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		if len(results) != 2 || results[1].Region != "eu-west-1" || results[1].Identity.Account != "012345678912" {
+			t.Errorf("expected an identity for every check, got: %+v", results)
+		}
+	})
+
+	t.Run("Preflight failure", func(t *testing.T) {
+		denied := errors.New("AccessDenied")
+		results, err := aws.Preflight(context.TODO(), []aws.Check{
+			{Name: "read", Config: newConfig("us-east-1", "", nil)},
+			{Name: "write", Config: newConfig("eu-west-1", "arn:aws:iam::012345678912:role/Balance", denied)},
+		})
+
+		if !errors.Is(err, denied) || !strings.Contains(err.Error(), "write in eu-west-1 as arn:aws:iam::012345678912:role/Balance") {
+			t.Errorf("expected the failing check to be named, got: %v", err)
+		}
+
+		if results[0].Identity == nil || results[1].Identity != nil {
+			t.Errorf("expected only the read identity, got: %+v", results)
+		}
+	})
+
+	t.Run("Preflight role chain", func(t *testing.T) {
+		hub, balance := "arn:aws:iam::111111111111:role/Hub", "arn:aws:iam::012345678912:role/Balance"
+		denied := errors.New("AccessDenied")
+
+		// every loaded config is numbered, the default config is 0 and each hop the next number
+		newChain := func(failHop string) *aws.ClientConfig {
+			loaded := 0
+			cfg := aws.NewClientConfig(context.TODO())
+			cfg.ConfigLoaderFn = func(ctx context.Context, optFns ...func(*config.LoadOptions) error) (awsSDK.Config, error) {
+				id := fmt.Sprint(loaded)
+				loaded++
+				return awsSDK.Config{AppID: id}, nil
+			}
+			cfg.STSClientFn = func(c awsSDK.Config) aws.STSClient {
+				return &FakeSTSClient{
+					GetCallerIdentityFn: func(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+						if c.AppID == failHop {
+							return nil, denied
+						}
+
+						return &sts.GetCallerIdentityOutput{
+							Account: awsSDK.String("0" + c.AppID),
+							Arn:     awsSDK.String("arn:aws:sts::0" + c.AppID + ":assumed-role/session"),
+						}, nil
+					},
+				}
+			}
+
+			if err := cfg.Default("eu-west-1"); err != nil {
+				t.Fatal(err)
+			}
+			for _, role := range []string{hub, balance} {
+				if err := cfg.AssumeRole("eu-west-1", role); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			return cfg
+		}
+
+		results, err := aws.Preflight(context.TODO(), []aws.Check{{Name: "write", Config: newChain("")}})
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		if results[0].Identity.Account != "02" {
+			t.Errorf("expected the identity of the last hop, got: %+v", results[0].Identity)
+		}
+
+		_, err = aws.Preflight(context.TODO(), []aws.Check{{Name: "write", Config: newChain("1")}})
+		if !errors.Is(err, denied) || !strings.Contains(err.Error(), "hop 1 of 2, "+hub) {
+			t.Errorf("expected the failing hop to be named, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package aws
+
+import (
+	"slices"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// WithRoleOptions sets the options of an assumed role session, unset options
+// keep the SDK defaults. In a role chain they only apply to the final role,
+// the hops before it get WithChainedRoleOptions.
+func WithRoleOptions(o config.RoleOptions) func(*stscreds.AssumeRoleOptions) {
+	return func(a *stscreds.AssumeRoleOptions) {
+		WithChainedRoleOptions(o)(a)
+
+		if o.ExternalId != "" {
+			a.ExternalID = aws.String(o.ExternalId)
+		}
+		if o.SourceIdentity != "" {
+			a.SourceIdentity = aws.String(o.SourceIdentity)
+		}
+
+		keys := make([]string, 0, len(o.Tags))
+		for key := range o.Tags {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			a.Tags = append(a.Tags, types.Tag{Key: aws.String(key), Value: aws.String(o.Tags[key])})
+		}
+	}
+}
+
+// WithChainedRoleOptions sets the session name and duration of a role assumed
+// on the way to the final role of a chain. The external ID, tags and source
+// identity are meant for the trust policy of the final role, so a hub role
+// doesn't need to allow sts:TagSession and sts:SetSourceIdentity.
+func WithChainedRoleOptions(o config.RoleOptions) func(*stscreds.AssumeRoleOptions) {
+	return func(a *stscreds.AssumeRoleOptions) {
+		if o.SessionName != "" {
+			a.RoleSessionName = o.SessionName
+		}
+		if o.Duration > 0 {
+			a.Duration = o.Duration
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package aws_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws-powertools/actions/layer-balancer/aws"
+	"github.com/aws-powertools/actions/layer-balancer/config"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+)
+
+func TestWithRoleOptions(t *testing.T) {
+	t.Run("WithRoleOptions", func(t *testing.T) {
+		var options stscreds.AssumeRoleOptions
+		aws.WithRoleOptions(config.RoleOptions{
+			ExternalId:     "powertools-release",
+			SessionName:    "1234567890-release",
+			Duration:       time.Hour,
+			Tags:           map[string]string{"run": "1234567890", "layer": "python"},
+			SourceIdentity: "release-bot",
+		})(&options)
+
+		if awsSDK.ToString(options.ExternalID) != "powertools-release" || options.RoleSessionName != "1234567890-release" || options.Duration != time.Hour || awsSDK.ToString(options.SourceIdentity) != "release-bot" {
+			t.Errorf("expected every option to be set, got: %+v", options)
+		}
+
+		if len(options.Tags) != 2 || awsSDK.ToString(options.Tags[0].Key) != "layer" || awsSDK.ToString(options.Tags[1].Value) != "1234567890" {
+			t.Errorf("expected the tags sorted by key, got: %+v", options.Tags)
+		}
+	})
+
+	t.Run("WithRoleOptions defaults", func(t *testing.T) {
+		options := stscreds.AssumeRoleOptions{RoleSessionName: "default", Duration: stscreds.DefaultDuration}
+		aws.WithRoleOptions(config.RoleOptions{})(&options)
+
+		if options.RoleSessionName != "default" || options.Duration != stscreds.DefaultDuration || options.ExternalID != nil || options.Tags != nil {
+			t.Errorf("expected the defaults to be kept, got: %+v", options)
+		}
+	})
+
+	t.Run("WithChainedRoleOptions", func(t *testing.T) {
+		var options stscreds.AssumeRoleOptions
+		aws.WithChainedRoleOptions(config.RoleOptions{
+			ExternalId:     "powertools-release",
+			SessionName:    "1234567890-release",
+			Duration:       time.Hour,
+			Tags:           map[string]string{"run": "1234567890"},
+			SourceIdentity: "release-bot",
+		})(&options)
+
+		if options.RoleSessionName != "1234567890-release" || options.Duration != time.Hour {
+			t.Errorf("expected the session name and duration to be set, got: %+v", options)
+		}
+
+		if options.ExternalID != nil || options.Tags != nil || options.SourceIdentity != nil {
+			t.Errorf("expected the final role options to be left out, got: %+v", options)
+		}
+	})
+}
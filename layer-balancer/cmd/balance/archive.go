@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+)
+
+func runExport(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: balance export -out <dir|file.tar> [options] \n\n")
+		fmt.Fprintf(os.Stderr, "Writes every version of the selected layers in the read region, each package with a JSON sidecar.\n\n")
+		fmt.Fprintf(os.Stderr, "flags:\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+
+	targets := addTargetFlags(fs)
+	out := fs.String("out", "", "directory to export to, or a file ending in .tar")
+	startAt := fs.Int64("start-at", 1, "Layer version to start exporting from")
+	parallelism := fs.Int("parallelism", 4, "number of layer versions downloaded at once")
+	mirror := fs.Bool("mirror-policy", false, "record the policy of each version so import -mirror-policy can reproduce it")
+	fs.Parse(args)
+
+	if *out == "" {
+		fs.Usage()
+	}
+
+	jobs, err := targets.jobs(config.WithStartAt(*startAt), config.WithParallelism(*parallelism))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := export(ctx, targets, jobs, *out, *mirror); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// export writes the layers of every job to out, a failed export removes what
+// it wrote.
+func export(ctx context.Context, targets *targetFlags, jobs []config.Job, out string, mirror bool) (err error) {
+	// balancers stop the run on a failed preflight, so they are all made
+	// before anything is written
+	balancers := make([]*layers.Balancer, len(jobs))
+	for i, job := range jobs {
+		job.Config.Permissions.Mirror = job.Config.Permissions.Mirror || mirror
+		balancers[i] = newBalancer(ctx, job.Config)
+	}
+
+	w, err := layers.NewArchiveWriter(out)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			err = w.Close()
+		}
+		if err != nil {
+			if abortErr := w.Abort(); abortErr != nil {
+				err = errors.Join(err, fmt.Errorf("removing the partial export %s: %w", out, abortErr))
+			}
+		}
+	}()
+
+	for i, job := range jobs {
+		balancer := balancers[i]
+
+		names, err := targets.layers(ctx, balancer.ReadClient, job)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			n, err := balancer.Export(ctx, name, w)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+
+			fmt.Printf("%s\texported %d versions\n", name, n)
+		}
+	}
+
+	return nil
+}
+
+func runImport(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: balance import -in <dir|file.tar> [options] \n\n")
+		fmt.Fprintf(os.Stderr, "Publishes an export to the write regions, versions already present are skipped.\n")
+		fmt.Fprintf(os.Stderr, "Every layer in the archive is imported unless -layer-name is set.\n\n")
+		fmt.Fprintf(os.Stderr, "flags:\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+
+	dryRun := fs.Bool("dry-run", true, "explicitly set to false to perform operation")
+	targets := addTargetFlags(fs)
+	permissions := addPermissionFlags(fs)
+	in := fs.String("in", "", "directory or .tar file written by balance export")
+	startAt := fs.Int64("start-at", 1, "Layer version to start importing from")
+	concurrency := fs.Int("concurrency", 4, "number of write regions to import to at once")
+	alignVersions := fs.Bool("align-versions", false, "publish placeholder versions so destination version numbers match the archive, placeholders are deleted afterwards")
+	mapping := addMappingFlags(fs)
+	fs.Parse(args)
+
+	if *in == "" {
+		fs.Usage()
+	}
+
+	if err := mapping.validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	if targets.set("layer-prefix") || targets.set("layer-glob") {
+		log.Fatal("import selects layers with -layer-name only")
+	}
+
+	archive, err := layers.OpenArchive(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer archive.Close()
+
+	jobs, err := targets.jobs(config.WithStartAt(*startAt), config.WithConcurrency(*concurrency))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var results []layers.LayerResult
+	for _, job := range jobs {
+		job.Config.DryRun = *dryRun
+		job.Config.AlignVersions = job.Config.AlignVersions || *alignVersions
+		permissions.apply(job.Config)
+
+		names := job.Layers
+		if len(names) == 0 {
+			if names, err = archive.Layers(); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		balancer := newBalancer(ctx, job.Config)
+		for _, name := range names {
+			regions, err := balancer.Import(ctx, archive, name)
+			results = append(results, layers.LayerResult{
+				LayerName: name,
+				Regions:   regions,
+				Err:       err,
+			})
+		}
+	}
+
+	if err := mapping.write(results); err != nil {
+		archive.Close()
+		log.Fatal(err)
+	}
+
+	if failed := printSummary(os.Stdout, results); failed > 0 {
+		archive.Close()
+		log.Fatalf("%d of %d layers failed", failed, len(results))
+	}
+}
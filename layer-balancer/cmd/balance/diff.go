@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+)
+
+func runDiff(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	targets := addTargetFlags(fs)
+	format := fs.String("format", "text", "output format, one of text, json or markdown")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: balance diff [options] \n\n")
+		fmt.Fprintf(os.Stderr, "Compares every version of the selected layers between the read and write regions.\n")
+		fmt.Fprintf(os.Stderr, "Exits with 1 when drift is found.\n\n")
+		fmt.Fprintf(os.Stderr, "flags:\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+
+	write, ok := diffWriters[*format]
+	if !ok {
+		log.Fatalf("unknown format %q", *format)
+	}
+
+	jobs, err := targets.jobs()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// a region that can't be compared doesn't hide the drift found in the others
+	diffs := []*layers.LayerDiff{}
+	var errs []error
+	for _, job := range jobs {
+		balancer := newBalancer(ctx, job.Config)
+
+		names, err := targets.layers(ctx, balancer.ReadClient, job)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, name := range names {
+			destName, err := balancer.DestinationName(name)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			for _, dest := range balancer.Destinations {
+				diff, err := layers.Diff(ctx, balancer.ReadClient, dest, job.Config.ReadRegion, name, destName)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s in %s: %w", name, dest.Region, err))
+					continue
+				}
+
+				diffs = append(diffs, diff)
+			}
+		}
+	}
+
+	if err := write(os.Stdout, diffs); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, diff := range diffs {
+		if diff.Drifted() {
+			os.Exit(1)
+		}
+	}
+}
+
+var diffWriters = map[string]func(w io.Writer, diffs []*layers.LayerDiff) error{
+	"text":     writeDiffText,
+	"json":     writeDiffJSON,
+	"markdown": writeDiffMarkdown,
+}
+
+func writeDiffText(w io.Writer, diffs []*layers.LayerDiff) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LAYER\tREGION\tVERSION\tSOURCE\tDESTINATION\tDRIFT")
+	for _, d := range diffs {
+		for _, v := range d.Versions {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", d.LayerName, d.WriteRegion, v.Version, orDash(v.SourceArn), orDash(v.DestinationArn), driftText(v.Drift))
+		}
+	}
+
+	return tw.Flush()
+}
+
+func writeDiffJSON(w io.Writer, diffs []*layers.LayerDiff) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(diffs)
+}
+
+func writeDiffMarkdown(w io.Writer, diffs []*layers.LayerDiff) error {
+	fmt.Fprintln(w, "| Layer | Region | Version | Source | Destination | Drift |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- | --- | --- |")
+	for _, d := range diffs {
+		for _, v := range d.Versions {
+			if _, err := fmt.Fprintf(w, "| %s | %s | %d | %s | %s | %s |\n", d.LayerName, d.WriteRegion, v.Version, orDash(v.SourceArn), orDash(v.DestinationArn), driftText(v.Drift)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func driftText(drift []string) string {
+	if len(drift) == 0 {
+		return "ok"
+	}
+
+	return strings.Join(drift, ", ")
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+)
+
+// targetFlags selects the regions and layers a command works on, either from
+// flags or from a manifest.
+type targetFlags struct {
+	fs *flag.FlagSet
+
+	readRegion     *string
+	readRole       *string
+	readRoleChain  *string
+	writeRegion    *string
+	writeRole      *string
+	writeRoleChain *string
+	layerName      *string
+	layerPrefix    *string
+	layerGlob      *string
+	manifest       *string
+
+	nameMap      *string
+	namePattern  *string
+	nameTemplate *string
+
+	role *roleFlags
+}
+
+func addTargetFlags(fs *flag.FlagSet) *targetFlags {
+	return &targetFlags{
+		fs:             fs,
+		readRegion:     fs.String("read-region", "", "known good region with a complete layer history"),
+		readRole:       fs.String("read-role", "", "role ARN for read operations, the default credentials are used when unset"),
+		readRoleChain:  fs.String("read-role-chain", "", "comma separated role ARNs assumed in order before -read-role, e.g. a hub role"),
+		writeRegion:    fs.String("write-region", "", "comma separated regions the new layer will exist in, this doesn't have to be the same account, use region=roleARN to override -write-role for a region"),
+		writeRole:      fs.String("write-role", "", "role ARN for write operation, it has to be assumable by your environment role"),
+		writeRoleChain: fs.String("write-role-chain", "", "comma separated role ARNs assumed in order before the role of every write region, e.g. a hub role"),
+		layerName:      fs.String("layer-name", "", "comma separated layer names to copy to another region"),
+		layerPrefix:    fs.String("layer-prefix", "", "copy every layer in the read region whose name starts with this prefix"),
+		layerGlob:      fs.String("layer-glob", "", "copy every layer in the read region whose name matches this glob, e.g. 'AWSLambdaPowertoolsPythonV3-*'"),
+		manifest:       fs.String("manifest", "", "YAML or JSON manifest describing the layers, source and destination regions to balance, replaces the region and layer flags"),
+
+		nameMap:      fs.String("name-map", "", "comma separated source=destination layer names to rename in the write regions"),
+		namePattern:  fs.String("name-pattern", "", "regular expression matching whole source layer names to rename with -name-template"),
+		nameTemplate: fs.String("name-template", "", "destination layer name for -name-pattern, captured parts are referenced as $1 or ${name}"),
+
+		role: addRoleFlags(fs),
+	}
+}
+
+// jobs loads the manifest when -manifest is set, otherwise it builds a single
+// job from the region and layer flags, opts are only applied to the latter.
+// The name mapping and role flags apply to every job.
+func (t *targetFlags) jobs(opts ...config.Option) ([]config.Job, error) {
+	jobs, err := t.loadJobs(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping, err := t.nameMapping()
+	if err != nil {
+		return nil, err
+	}
+
+	if !mapping.Empty() {
+		for _, job := range jobs {
+			job.Config.NameMapping = mapping
+		}
+	}
+
+	for _, job := range jobs {
+		if err := t.role.apply(job.Config); err != nil {
+			return nil, err
+		}
+	}
+
+	return jobs, nil
+}
+
+func (t *targetFlags) nameMapping() (config.NameMapping, error) {
+	mapping := config.NameMapping{
+		Pattern:  *t.namePattern,
+		Template: *t.nameTemplate,
+	}
+
+	for _, pair := range splitList(*t.nameMap) {
+		source, dest, found := strings.Cut(pair, "=")
+		if !found || source == "" || dest == "" {
+			return mapping, fmt.Errorf("-name-map %q is not a source=destination pair", pair)
+		}
+
+		if mapping.Table == nil {
+			mapping.Table = map[string]string{}
+		}
+		mapping.Table[source] = dest
+	}
+
+	if mapping.Template != "" && mapping.Pattern == "" {
+		return mapping, fmt.Errorf("-name-template requires -name-pattern")
+	}
+
+	return mapping, mapping.Validate()
+}
+
+func (t *targetFlags) loadJobs(opts ...config.Option) ([]config.Job, error) {
+	if *t.manifest != "" {
+		var err error
+		t.fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "read-region", "read-role", "read-role-chain", "write-region", "write-role", "write-role-chain", "layer-name", "layer-prefix", "layer-glob", "start-at":
+				err = fmt.Errorf("-%s can't be combined with -manifest", f.Name)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		m, err := config.LoadManifest(*t.manifest)
+		if err != nil {
+			return nil, err
+		}
+
+		return m.Jobs(), nil
+	}
+
+	opts = append([]config.Option{
+		config.WithReadRegion(*t.readRegion),
+		config.WithReadRole(*t.readRole, splitList(*t.readRoleChain)...),
+	}, opts...)
+
+	chain := splitList(*t.writeRoleChain)
+	for _, region := range splitList(*t.writeRegion) {
+		region, role, found := strings.Cut(region, "=")
+		if !found {
+			role = *t.writeRole
+		}
+
+		opts = append(opts, config.WithWriteRegion(region, role), config.WithWriteRoleChain(region, chain...))
+	}
+
+	return []config.Job{{
+		Config: config.NewConfig(opts...),
+		Layers: splitList(*t.layerName),
+	}}, nil
+}
+
+func (t *targetFlags) layers(ctx context.Context, client layers.LambdaClient, job config.Job) ([]string, error) {
+	names, err := layers.ResolveLayers(ctx, client, job.Layers, *t.layerPrefix, *t.layerGlob)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no layers selected, set -layer-name, -layer-prefix, -layer-glob or -manifest")
+	}
+
+	return names, nil
+}
+
+// set reports whether the flag was passed on the command line.
+func (t *targetFlags) set(name string) bool {
+	found := false
+	t.fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+
+	return found
+}
+
+// sourceFlags selects a source by ARN instead of by name in the read region.
+type sourceFlags struct {
+	arn             *string
+	destinationName *string
+}
+
+func addSourceFlags(fs *flag.FlagSet) *sourceFlags {
+	return &sourceFlags{
+		arn:             fs.String("source-arn", "", "layer ARN to copy, optionally with a version, the layer can be shared from another account"),
+		destinationName: fs.String("destination-name", "", "layer name in the write regions when copying -source-arn, defaults to the source name"),
+	}
+}
+
+// source parses -source-arn, it returns nil when it isn't set. The read region
+// comes from the ARN, so it can't be combined with other layer selections.
+func (s *sourceFlags) source(targets *targetFlags) (*layers.LayerArn, error) {
+	if *s.arn == "" {
+		if *s.destinationName != "" {
+			return nil, fmt.Errorf("-destination-name requires -source-arn")
+		}
+		return nil, nil
+	}
+
+	for _, name := range []string{"layer-name", "layer-prefix", "layer-glob", "manifest"} {
+		if targets.set(name) {
+			return nil, fmt.Errorf("-%s can't be combined with -source-arn", name)
+		}
+	}
+
+	arn, err := layers.ParseLayerArn(*s.arn)
+	if err != nil {
+		return nil, err
+	}
+
+	if *targets.readRegion != "" && *targets.readRegion != arn.Region {
+		return nil, fmt.Errorf("-read-region %s doesn't match the region of -source-arn %s", *targets.readRegion, arn.Region)
+	}
+
+	return &arn, nil
+}
+
+type permissionFlags struct {
+	mirror   *bool
+	public   *bool
+	org      *string
+	accounts *string
+}
+
+func addPermissionFlags(fs *flag.FlagSet) *permissionFlags {
+	return &permissionFlags{
+		mirror:   fs.Bool("mirror-policy", false, "reproduce the permissions of each source version instead of making copies public"),
+		public:   fs.Bool("share-public", false, "make copies usable by every account, overrides -mirror-policy"),
+		org:      fs.String("share-org", "", "AWS Organization ID allowed to use copies, overrides -mirror-policy"),
+		accounts: fs.String("share-accounts", "", "comma separated account IDs allowed to use copies, overrides -mirror-policy"),
+	}
+}
+
+// apply replaces the permissions of cfg when any permission flag is set.
+func (p *permissionFlags) apply(cfg *config.Config) {
+	permissions := config.Permissions{
+		Mirror:         *p.mirror,
+		Public:         *p.public,
+		OrganizationId: *p.org,
+		Accounts:       splitList(*p.accounts),
+	}
+
+	if permissions.Mirror || permissions.Explicit() {
+		cfg.Permissions = permissions
+	}
+}
+
+type stagingFlags struct {
+	buckets   *string
+	threshold *int64
+}
+
+func addStagingFlags(fs *flag.FlagSet) *stagingFlags {
+	return &stagingFlags{
+		buckets:   fs.String("staging-bucket", "", "comma separated region=bucket pairs, packages above -staging-threshold are published through the bucket of their write region"),
+		threshold: fs.Int64("staging-threshold", layers.DefaultStagingThreshold>>20, "package size in MiB above which a staging bucket is used"),
+	}
+}
+
+// apply sets the staging buckets of every job, a bucket for a region that
+// none of the jobs writes to is an error.
+func (s *stagingFlags) apply(jobs []config.Job) error {
+	buckets := map[string]string{}
+	for _, pair := range splitList(*s.buckets) {
+		region, bucket, found := strings.Cut(pair, "=")
+		if !found || region == "" || bucket == "" {
+			return fmt.Errorf("-staging-bucket %q is not a region=bucket pair", pair)
+		}
+		buckets[region] = bucket
+	}
+
+	used := map[string]bool{}
+	for _, job := range jobs {
+		job.Config.StagingThreshold = *s.threshold << 20
+		for _, w := range job.Config.WriteRegions {
+			if bucket, ok := buckets[w.Region]; ok {
+				config.WithStagingBucket(w.Region, bucket)(job.Config)
+				used[w.Region] = true
+			}
+		}
+	}
+
+	for region := range buckets {
+		if !used[region] {
+			return fmt.Errorf("-staging-bucket: %s is not a write region", region)
+		}
+	}
+
+	return nil
+}
+
+// roleFlags set the session options of every assumed role.
+type roleFlags struct {
+	externalId     *string
+	sessionName    *string
+	duration       *time.Duration
+	tags           *string
+	sourceIdentity *string
+}
+
+func addRoleFlags(fs *flag.FlagSet) *roleFlags {
+	return &roleFlags{
+		externalId:     fs.String("role-external-id", "", "external ID required by the trust policy of the assumed roles, only sent to the final role of a chain"),
+		sessionName:    fs.String("role-session-name", "", "session name of the assumed roles as seen in CloudTrail, e.g. the run ID and layer"),
+		duration:       fs.Duration("role-duration", 0, "duration of the assumed role sessions, between 15m and 12h, the SDK default when unset"),
+		tags:           fs.String("role-session-tags", "", "comma separated key=value session tags of the assumed roles, only sent to the final role of a chain"),
+		sourceIdentity: fs.String("role-source-identity", "", "source identity of the assumed role sessions, only sent to the final role of a chain"),
+	}
+}
+
+func (r *roleFlags) apply(cfg *config.Config) error {
+	options := config.RoleOptions{
+		ExternalId:     *r.externalId,
+		SessionName:    *r.sessionName,
+		Duration:       *r.duration,
+		SourceIdentity: *r.sourceIdentity,
+	}
+
+	for _, pair := range splitList(*r.tags) {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" {
+			return fmt.Errorf("-role-session-tags %q is not a key=value pair", pair)
+		}
+
+		if options.Tags == nil {
+			options.Tags = map[string]string{}
+		}
+		options.Tags[key] = value
+	}
+
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	cfg.RoleOptions = options
+
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -4,24 +4,59 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"sort"
 
 	"github.com/aws-powertools/actions/layer-balancer/config"
 	"github.com/aws-powertools/actions/layer-balancer/layers"
 )
 
 var (
-	dryRun      = flag.Bool("dry-run", true, "explicitly set to false to perform operation")
-	readRegion  = flag.String("read-region", "", "known good region with a complete layer history")
-	writeRegion = flag.String("write-region", "", "region the new layer will exist in, this doesn't have to be the same account")
-	writeRole   = flag.String("write-role", "", "role ARN for write operation, it has to be assumable by your environment role")
-	layerName   = flag.String("layer-name", "", "layer name to copy to another region")
+	dryRun  = flag.Bool("dry-run", true, "explicitly set to false to perform operation")
+	targets = addTargetFlags(flag.CommandLine)
+	source  = addSourceFlags(flag.CommandLine)
 
-	startAt = flag.Int64("start-at", 1, "Layer version to start backfilling from")
+	startAt     = flag.Int64("start-at", 1, "Layer version to start backfilling from")
+	concurrency = flag.Int("concurrency", 4, "number of write regions to copy to at once")
+	parallelism = flag.Int("parallelism", 4, "number of layer versions enriched and downloaded at once, versions are still published in order")
+
+	alignVersions = flag.Bool("align-versions", false, "publish placeholder versions so destination version numbers match the source, placeholders are deleted afterwards")
+
+	permissions = addPermissionFlags(flag.CommandLine)
+
+	downloadTimeout = flag.Duration("download-timeout", layers.DefaultDownloadTimeout, "time allowed for a single layer package download attempt")
+	maxPackageSize  = flag.Int64("max-package-size", layers.DefaultMaxPackageSize>>20, "largest layer package in MiB that will be downloaded")
+
+	cacheDir  = flag.String("cache-dir", "", "directory to keep downloaded packages in between runs, packages are only shared within a run when unset")
+	cacheSize = flag.Int64("cache-size", layers.DefaultDiskCacheSize>>20, "largest size in MiB of -cache-dir")
+
+	staging = addStagingFlags(flag.CommandLine)
+	mapping = addMappingFlags(flag.CommandLine)
 )
 
+var commands = map[string]func(ctx context.Context, args []string){
+	"apply":       runApply,
+	"diff":        runDiff,
+	"export":      runExport,
+	"import":      runImport,
+	"permissions": runPermissions,
+	"plan":        runPlan,
+	"publish":     runPublish,
+	"ssm":         runSSM,
+}
+
 func main() {
+	ctx := context.Background()
+
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			command(ctx, os.Args[2:])
+			return
+		}
+	}
+
 	flag.Usage = usage
 	flag.Parse()
 
@@ -29,25 +64,111 @@ func main() {
 	// 	usage()
 	// }
 
-	ctx := context.Background()
+	sourceArn, err := source.source(targets)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := mapping.validate(); err != nil {
+		log.Fatal(err)
+	}
 
-	cfg := config.NewConfig(
-		config.WithReadRegion(*readRegion),
-		config.WithWriteRegion(*writeRegion),
-		config.WithWriteRole(*writeRole),
+	opts := []config.Option{
 		config.WithStartAt(*startAt),
-	)
+		config.WithConcurrency(*concurrency),
+		config.WithParallelism(*parallelism),
+	}
+	if sourceArn != nil {
+		opts = append(opts, config.WithReadRegion(sourceArn.Region))
+	}
+
+	jobs, err := targets.jobs(opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := staging.apply(jobs); err != nil {
+		log.Fatal(err)
+	}
+
+	var results []layers.LayerResult
+	for _, job := range jobs {
+		job.Config.DryRun = *dryRun
+		job.Config.MaxPackageSize = *maxPackageSize << 20
+		job.Config.DownloadTimeout = *downloadTimeout
+		config.WithCacheDir(*cacheDir, *cacheSize<<20)(job.Config)
+		job.Config.AlignVersions = job.Config.AlignVersions || *alignVersions
+		if targets.set("concurrency") {
+			job.Config.Concurrency = *concurrency
+		}
+		if targets.set("parallelism") {
+			job.Config.Parallelism = *parallelism
+		}
+		permissions.apply(job.Config)
+
+		balancer := newBalancer(ctx, job.Config)
+
+		if sourceArn != nil {
+			regions, err := balancer.BalanceArn(ctx, *sourceArn, *source.destinationName)
+			results = append(results, layers.LayerResult{LayerName: sourceArn.Name, Regions: regions, Err: err})
+			continue
+		}
 
-	cfg.DryRun = *dryRun
+		names, err := targets.layers(ctx, balancer.ReadClient, job)
+		if err != nil {
+			log.Fatal(err)
+		}
 
-	if err := layers.Balance(ctx, cfg, *layerName); err != nil {
+		results = append(results, balancer.BalanceAll(ctx, names)...)
+	}
+
+	if err := mapping.write(results); err != nil {
 		log.Fatal(err)
 	}
+
+	if failed := printSummary(os.Stdout, results); failed > 0 {
+		log.Fatalf("%d of %d layers failed", failed, len(results))
+	}
+}
+
+func printSummary(w io.Writer, results []layers.LayerResult) int {
+	failed := 0
+	for _, r := range results {
+		if r.Failed() {
+			failed++
+		}
+
+		if r.Err != nil {
+			fmt.Fprintf(w, "%s\tfailed: %v\n", r.LayerName, r.Err)
+			continue
+		}
+
+		for _, region := range r.Regions {
+			if region.Err != nil {
+				fmt.Fprintf(w, "%s\t%s\tfailed: %v\n", r.LayerName, region.Region, region.Err)
+				continue
+			}
+
+			fmt.Fprintf(w, "%s\t%s\tok: %d copied, %d skipped\n", r.LayerName, region.Region, region.Copied, region.Skipped)
+		}
+	}
+
+	fmt.Fprintf(w, "%d layers, %d ok, %d failed\n", len(results), len(results)-failed, failed)
+
+	return failed
 }
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: balance [options] \n\n")
-	fmt.Fprintf(os.Stderr, "flags:\n")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "usage: balance [options] \n")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "       balance %s [options]\n", name)
+	}
+	fmt.Fprintf(os.Stderr, "\nflags:\n")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+)
+
+// mappingFlags write the source to destination ARN mapping of a run to a file.
+type mappingFlags struct {
+	out    *string
+	format *string
+}
+
+func addMappingFlags(fs *flag.FlagSet) *mappingFlags {
+	return &mappingFlags{
+		out:    fs.String("mapping-out", "", "write the source to destination layer version ARN mapping of every version to this file"),
+		format: fs.String("mapping-format", "json", "format of -mapping-out, one of json or csv"),
+	}
+}
+
+// validate is called before a run so a typo doesn't surface after it.
+func (m *mappingFlags) validate() error {
+	if _, ok := mappingWriters[*m.format]; !ok {
+		return fmt.Errorf("unknown mapping format %q", *m.format)
+	}
+
+	return nil
+}
+
+func (m *mappingFlags) write(results []layers.LayerResult) error {
+	if *m.out == "" {
+		return nil
+	}
+
+	f, err := os.Create(*m.out)
+	if err != nil {
+		return err
+	}
+
+	if err := mappingWriters[*m.format](f, layers.ArnMappings(results)); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+var mappingWriters = map[string]func(w io.Writer, mappings []layers.ArnMapping) error{
+	"json": writeMappingJSON,
+	"csv":  writeMappingCSV,
+}
+
+func writeMappingJSON(w io.Writer, mappings []layers.ArnMapping) error {
+	if mappings == nil {
+		mappings = []layers.ArnMapping{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(mappings)
+}
+
+func writeMappingCSV(w io.Writer, mappings []layers.ArnMapping) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"layer_name", "region", "version", "source_arn", "destination_arn", "code_sha256", "status"})
+	for _, m := range mappings {
+		cw.Write([]string{m.LayerName, m.Region, strconv.FormatInt(m.Version, 10), m.SourceArn, m.DestinationArn, m.CodeSha256, string(m.Status)})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
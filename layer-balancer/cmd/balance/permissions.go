@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+)
+
+func runPermissions(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("permissions", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: balance permissions audit|repair [options] \n\n")
+		fmt.Fprintf(os.Stderr, "Checks the policy of every version of the selected layers in the write regions.\n")
+		fmt.Fprintf(os.Stderr, "repair adds missing statements, and removes unexpected ones with -remove-extra.\n\n")
+		fmt.Fprintf(os.Stderr, "flags:\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+
+	if len(args) == 0 || (args[0] != "audit" && args[0] != "repair") {
+		fs.Usage()
+	}
+	repair := args[0] == "repair"
+
+	dryRun := fs.Bool("dry-run", true, "explicitly set to false to perform operation")
+	targets := addTargetFlags(fs)
+	permissions := addPermissionFlags(fs)
+	removeExtra := fs.Bool("remove-extra", false, "remove statements that are not expected, also reports them as problems in audit")
+	format := fs.String("format", "text", "output format, one of text or json")
+	fs.Parse(args[1:])
+
+	if *format != "text" && *format != "json" {
+		log.Fatalf("unknown format %q", *format)
+	}
+
+	jobs, err := targets.jobs()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts := layers.AuditOptions{
+		Repair:      repair,
+		RemoveExtra: *removeExtra,
+	}
+
+	var audits []layers.PermissionAudit
+	for _, job := range jobs {
+		job.Config.DryRun = *dryRun
+		permissions.apply(job.Config)
+
+		balancer := newBalancer(ctx, job.Config)
+
+		names, err := targets.layers(ctx, balancer.ReadClient, job)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, name := range names {
+			for _, dest := range balancer.Destinations {
+				result, err := balancer.AuditPermissions(ctx, dest, name, opts)
+				if err != nil {
+					log.Fatalf("%s in %s: %v", name, dest.Region, err)
+				}
+
+				audits = append(audits, result...)
+			}
+		}
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(audits); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "LAYER\tREGION\tVERSION\tSTATUS")
+		for _, a := range audits {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", a.LayerName, a.Region, a.Version, auditStatus(a))
+		}
+		tw.Flush()
+	}
+
+	for _, a := range audits {
+		if !a.Ok(*removeExtra) {
+			os.Exit(1)
+		}
+	}
+}
+
+func auditStatus(a layers.PermissionAudit) string {
+	if a.Error != "" {
+		return "error: " + a.Error
+	}
+
+	var status []string
+	if a.Repaired {
+		status = append(status, "repaired")
+	}
+
+	for _, p := range a.Missing {
+		status = append(status, "missing "+permissionText(p))
+	}
+
+	for _, p := range a.Extra {
+		status = append(status, "extra "+permissionText(p))
+	}
+
+	if len(status) == 0 {
+		return "ok"
+	}
+
+	return strings.Join(status, ", ")
+}
+
+func permissionText(p layers.Permission) string {
+	if p.OrganizationId != "" {
+		return fmt.Sprintf("%s (%s in %s)", p.StatementId, p.Principal, p.OrganizationId)
+	}
+
+	return fmt.Sprintf("%s (%s)", p.StatementId, p.Principal)
+}
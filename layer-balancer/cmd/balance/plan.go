@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+)
+
+func runPlan(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: balance plan [options] \n\n")
+		fmt.Fprintf(os.Stderr, "Writes every version a balance run would copy, with its expected destination version\n")
+		fmt.Fprintf(os.Stderr, "and permissions, as JSON for balance apply. Nothing is changed.\n\n")
+		fmt.Fprintf(os.Stderr, "flags:\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+
+	targets := addTargetFlags(fs)
+	permissions := addPermissionFlags(fs)
+	staging := addStagingFlags(fs)
+	out := fs.String("out", "", "file to write the plan to, it is written to stdout when unset")
+	startAt := fs.Int64("start-at", 1, "Layer version to start backfilling from")
+	parallelism := fs.Int("parallelism", 4, "number of layer versions enriched at once")
+	fs.Parse(args)
+
+	jobs, err := targets.jobs(config.WithStartAt(*startAt), config.WithParallelism(*parallelism))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := staging.apply(jobs); err != nil {
+		log.Fatal(err)
+	}
+
+	plan := layers.Plan{CreatedAt: time.Now().UTC()}
+	for _, job := range jobs {
+		permissions.apply(job.Config)
+		if targets.set("parallelism") {
+			job.Config.Parallelism = *parallelism
+		}
+
+		balancer := newBalancer(ctx, job.Config)
+
+		names, err := targets.layers(ctx, balancer.ReadClient, job)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, name := range names {
+			layerPlans, err := balancer.Plan(ctx, name)
+			if err != nil {
+				log.Fatalf("%s: %v", name, err)
+			}
+
+			plan.Layers = append(plan.Layers, layerPlans...)
+		}
+	}
+
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	data = append(data, '\n')
+
+	if *out == "" {
+		os.Stdout.Write(data)
+		return
+	}
+
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LAYER\tREGION\tSOURCE\tEXPECTED VERSION")
+	for _, l := range plan.Layers {
+		for _, v := range l.Versions {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", l.LayerName, l.Region, v.SourceArn, v.ExpectedVersion)
+		}
+	}
+	tw.Flush()
+}
+
+func runApply(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: balance apply [options] <plan.json> \n\n")
+		fmt.Fprintf(os.Stderr, "Executes a plan written by balance plan. Nothing is applied when a source version\n")
+		fmt.Fprintf(os.Stderr, "or destination layer changed since the plan was made.\n\n")
+		fmt.Fprintf(os.Stderr, "flags:\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+
+	dryRun := fs.Bool("dry-run", true, "explicitly set to false to perform operation, a dry run only checks the plan")
+	concurrency := fs.Int("concurrency", 4, "number of layer plans to apply at once")
+	parallelism := fs.Int("parallelism", 4, "number of layer versions downloaded at once, versions are still published in order")
+	role := addRoleFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var plan layers.Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		log.Fatalf("%s: %v", fs.Arg(0), err)
+	}
+
+	// a balancer per read region and write region, with the roles and staging
+	// bucket the plan was made with
+	// the same package is downloaded once for every region it goes to
+	packages := layers.NewPackageStore()
+	defer packages.Close()
+
+	balancers := map[string]*layers.Balancer{}
+	balancerFor := func(l layers.LayerPlan) *layers.Balancer {
+		key := strings.Join([]string{l.ReadRegion, l.Region, l.StagingBucket, strings.Join(l.ReadRoleChain, ">"), l.ReadRole, strings.Join(l.RoleChain, ">"), l.Role}, "|")
+		if b, ok := balancers[key]; ok {
+			return b
+		}
+
+		cfg := config.NewConfig(
+			config.WithReadRegion(l.ReadRegion),
+			config.WithReadRole(l.ReadRole, l.ReadRoleChain...),
+			config.WithWriteRegion(l.Region, l.Role),
+			config.WithWriteRoleChain(l.Region, l.RoleChain...),
+			config.WithStagingBucket(l.Region, l.StagingBucket),
+			config.WithParallelism(*parallelism),
+		)
+		cfg.DryRun = *dryRun
+		if err := role.apply(cfg); err != nil {
+			log.Fatal(err)
+		}
+
+		b := newBalancer(ctx, cfg)
+		b.Packages = packages
+		balancers[key] = b
+
+		return b
+	}
+
+	stale := false
+	for _, l := range plan.Layers {
+		if _, err := balancerFor(l).Check(ctx, l); err != nil {
+			if !errors.Is(err, layers.ErrPlanStale) {
+				log.Fatalf("%s in %s: %v", l.LayerName, l.Region, err)
+			}
+
+			fmt.Fprintf(os.Stderr, "%s in %s: %v\n", l.LayerName, l.Region, err)
+			stale = true
+		}
+	}
+	if stale {
+		log.Fatal("plan is stale, nothing was applied")
+	}
+
+	if *concurrency < 1 {
+		*concurrency = 1
+	}
+
+	results := make([]layers.ApplyResult, len(plan.Layers))
+	sem := make(chan struct{}, *concurrency)
+
+	var wg sync.WaitGroup
+	for i, l := range plan.Layers {
+		b := balancerFor(l)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = b.Apply(ctx, l)
+		}()
+	}
+	wg.Wait()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	failed := 0
+	for i, r := range results {
+		switch {
+		case r.Err != nil:
+			failed++
+			fmt.Fprintf(tw, "%s\t%s\tfailed: %v\n", r.LayerName, r.Region, r.Err)
+		case *dryRun:
+			fmt.Fprintf(tw, "%s\t%s\tok: %d versions to copy\n", r.LayerName, r.Region, len(plan.Layers[i].Versions))
+		default:
+			fmt.Fprintf(tw, "%s\t%s\tok: %d copied\n", r.LayerName, r.Region, len(r.Published))
+		}
+	}
+	tw.Flush()
+
+	if failed > 0 {
+		packages.Close()
+		log.Fatalf("%d of %d layer plans failed", failed, len(results))
+	}
+}
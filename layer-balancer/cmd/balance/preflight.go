@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws-powertools/actions/layer-balancer/aws"
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+	"github.com/aws-powertools/actions/layer-balancer/parameters"
+)
+
+// newBalancer builds the balancer of cfg and checks that every config it uses
+// resolves to an identity, exiting otherwise.
+func newBalancer(ctx context.Context, cfg *config.Config) *layers.Balancer {
+	applyEndpoints(cfg)
+
+	balancer, err := layers.NewBalancer(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	preflight(balancer.Preflight(ctx))
+
+	return balancer
+}
+
+func newPublisher(ctx context.Context, cfg *config.Config, template parameters.Template) *parameters.Publisher {
+	applyEndpoints(cfg)
+
+	publisher, err := parameters.NewPublisher(ctx, cfg, template)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	preflight(publisher.Preflight(ctx))
+
+	return publisher
+}
+
+// applyEndpoints fills the endpoints unset in cfg from the environment.
+func applyEndpoints(cfg *config.Config) {
+	endpoints, err := config.EndpointsFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg.Endpoints = cfg.Endpoints.Merge(endpoints)
+}
+
+func preflight(results []aws.CheckResult, err error) {
+	for _, r := range results {
+		if r.Identity != nil {
+			log.Printf("Preflight: %s in %s as %s, account %s", r.Name, r.Region, r.Identity.Arn, r.Identity.Account)
+		}
+	}
+
+	if err != nil {
+		log.Fatalf("preflight failed: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func runPublish(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: balance publish -zip <file> -layer-name <name> -write-region <regions> [options] \n\n")
+		fmt.Fprintf(os.Stderr, "Publishes a local package as the next version of the layer in every write region.\n")
+		fmt.Fprintf(os.Stderr, "A region whose latest version already holds the package is left alone.\n\n")
+		fmt.Fprintf(os.Stderr, "flags:\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+
+	dryRun := fs.Bool("dry-run", true, "explicitly set to false to perform operation")
+	targets := addTargetFlags(fs)
+	permissions := addPermissionFlags(fs)
+	staging := addStagingFlags(fs)
+	zip := fs.String("zip", "", "layer package to publish")
+	description := fs.String("description", "", "description of the new version")
+	license := fs.String("license", "", "license info of the new version")
+	runtimes := fs.String("runtimes", "", "comma separated compatible runtimes, e.g. python3.12,python3.13")
+	architectures := fs.String("architectures", "", "comma separated compatible architectures, x86_64 and/or arm64")
+	concurrency := fs.Int("concurrency", 4, "number of write regions to publish to at once")
+	out := fs.String("out", "", "write a JSON map of region to layer version ARN to this file")
+	fs.Parse(args)
+
+	for _, name := range []string{"read-region", "read-role", "read-role-chain", "layer-prefix", "layer-glob", "manifest", "name-map", "name-pattern", "name-template"} {
+		if targets.set(name) {
+			log.Fatalf("-%s can't be used with publish", name)
+		}
+	}
+
+	jobs, err := targets.jobs(config.WithConcurrency(*concurrency))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := staging.apply(jobs); err != nil {
+		log.Fatal(err)
+	}
+	job := jobs[0]
+
+	if *zip == "" || len(job.Layers) != 1 || len(job.Config.WriteRegions) == 0 {
+		fs.Usage()
+	}
+
+	pkg := layers.LocalPackage{
+		LayerName:   job.Layers[0],
+		Path:        *zip,
+		Description: *description,
+		LicenseInfo: *license,
+	}
+
+	for _, r := range splitList(*runtimes) {
+		pkg.CompatibleRuntimes = append(pkg.CompatibleRuntimes, types.Runtime(r))
+	}
+
+	for _, a := range splitList(*architectures) {
+		arch := types.Architecture(a)
+		if !slices.Contains(arch.Values(), arch) {
+			log.Fatalf("unknown architecture %q", a)
+		}
+		pkg.CompatibleArchitectures = append(pkg.CompatibleArchitectures, arch)
+	}
+
+	job.Config.DryRun = *dryRun
+	permissions.apply(job.Config)
+
+	balancer := newBalancer(ctx, job.Config)
+
+	results, err := balancer.Publish(ctx, pkg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", pkg.LayerName, r.Region, r)
+	}
+	tw.Flush()
+
+	if *out != "" {
+		data, err := json.MarshalIndent(layers.ArnMap(results), "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := os.WriteFile(*out, append(data, '\n'), 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if failed > 0 {
+		log.Fatalf("%d of %d regions failed", failed, len(results))
+	}
+}
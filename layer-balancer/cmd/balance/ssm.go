@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/parameters"
+)
+
+func runSSM(ctx context.Context, args []string) {
+	if len(args) > 0 && args[0] == "verify" {
+		runSSMVerify(ctx, args[1:])
+		return
+	}
+
+	fs := flag.NewFlagSet("ssm", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: balance ssm -language <language> -layer-name <names> -write-region <regions> [options] \n")
+		fmt.Fprintf(os.Stderr, "       balance ssm verify -write-region <regions> [options] \n\n")
+		fmt.Fprintf(os.Stderr, "Points the SSM parameters of a release at its layer version in every write region.\n")
+		fmt.Fprintf(os.Stderr, "Release parameters pointing elsewhere are protected unless -overwrite is set.\n\n")
+		fmt.Fprintf(os.Stderr, "flags:\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+
+	dryRun := fs.Bool("dry-run", true, "explicitly set to false to perform operation, a dry run prints the parameter changes")
+	targets := addTargetFlags(fs)
+	language := fs.String("language", "", "{language} of the parameter names, e.g. python or typescript")
+	prefix := fs.String("prefix", parameters.DefaultPrefix, "{prefix} of the parameter names")
+	template := fs.String("template", parameters.DefaultTemplate, "parameter name template, {arch} and {runtime} come from the compatible architectures and runtimes of the layer version")
+	packageVersion := fs.String("package-version", "", "{version} of the release parameters, e.g. 3.4.0")
+	layerVersion := fs.Int64("layer-version", 0, "layer version the parameters point at, the latest version in each region when unset")
+	latest := fs.Bool("latest", false, "also point the latest parameters at the layer version")
+	overwrite := fs.Bool("overwrite", false, "replace release parameters that point at another layer version")
+	concurrency := fs.Int("concurrency", 4, "number of write regions to write parameters in at once")
+	fs.Parse(args)
+
+	for _, name := range []string{"read-region", "read-role", "read-role-chain", "layer-prefix", "layer-glob", "manifest", "name-map", "name-pattern", "name-template"} {
+		if targets.set(name) {
+			log.Fatalf("-%s can't be used with ssm", name)
+		}
+	}
+
+	tmpl, err := parameters.NewTemplate(*template)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	jobs, err := targets.jobs(config.WithConcurrency(*concurrency))
+	if err != nil {
+		log.Fatal(err)
+	}
+	job := jobs[0]
+
+	if *language == "" || len(job.Layers) == 0 || len(job.Config.WriteRegions) == 0 || (*packageVersion == "" && !*latest) {
+		fs.Usage()
+	}
+
+	var releases []parameters.Release
+	for _, name := range job.Layers {
+		releases = append(releases, parameters.Release{
+			LayerName:      name,
+			LayerVersion:   *layerVersion,
+			PackageVersion: *packageVersion,
+			Latest:         *latest,
+		})
+	}
+
+	job.Config.DryRun = *dryRun
+
+	publisher := newPublisher(ctx, job.Config, tmpl)
+	publisher.Prefix = *prefix
+	publisher.Language = *language
+	publisher.Overwrite = *overwrite
+
+	results := publisher.Publish(ctx, releases)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REGION\tPARAMETER\tACTION\tOLD\tNEW")
+	failed, protected := 0, 0
+	for _, r := range results {
+		for _, c := range r.Changes {
+			if c.Action == parameters.ActionProtected {
+				protected++
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Region, c.Name, c.Action, orDash(c.Old), c.New)
+		}
+
+		if r.Err != nil {
+			failed++
+			fmt.Fprintf(tw, "%s\t-\tfailed: %v\t\t\n", r.Region, r.Err)
+		}
+	}
+	tw.Flush()
+
+	if protected > 0 {
+		log.Printf("%d release parameters point at another layer version, set -overwrite to replace them", protected)
+	}
+
+	if failed > 0 {
+		log.Fatalf("%d of %d regions failed", failed, len(results))
+	}
+
+	if protected > 0 {
+		os.Exit(1)
+	}
+}
+
+func runSSMVerify(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("ssm verify", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: balance ssm verify -write-region <regions> [options] \n\n")
+		fmt.Fprintf(os.Stderr, "Checks that every parameter under -prefix points at an existing layer version in its region,\n")
+		fmt.Fprintf(os.Stderr, "that latest points at the highest version and that a release has the same layer version\n")
+		fmt.Fprintf(os.Stderr, "number in every region. Exits with 1 when a problem is found.\n\n")
+		fmt.Fprintf(os.Stderr, "flags:\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+
+	targets := addTargetFlags(fs)
+	prefix := fs.String("prefix", parameters.DefaultPrefix, "parameter path to verify, recursively")
+	concurrency := fs.Int("concurrency", 4, "number of write regions to verify at once")
+	fs.Parse(args)
+
+	for _, name := range []string{"read-region", "read-role", "read-role-chain", "layer-name", "layer-prefix", "layer-glob", "manifest", "name-map", "name-pattern", "name-template"} {
+		if targets.set(name) {
+			log.Fatalf("-%s can't be used with ssm verify", name)
+		}
+	}
+
+	jobs, err := targets.jobs(config.WithConcurrency(*concurrency))
+	if err != nil {
+		log.Fatal(err)
+	}
+	job := jobs[0]
+
+	if len(job.Config.WriteRegions) == 0 {
+		fs.Usage()
+	}
+
+	publisher := newPublisher(ctx, job.Config, parameters.Template{})
+	publisher.Prefix = *prefix
+
+	findings, err := publisher.Verify(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(findings) == 0 {
+		fmt.Printf("%s is consistent in %d regions\n", *prefix, len(job.Config.WriteRegions))
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REGION\tPARAMETER\tVALUE\tPROBLEM")
+	for _, f := range findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", orDash(f.Region), f.Name, orDash(f.Value), f.Problem)
+	}
+	tw.Flush()
+
+	os.Exit(1)
+}
@@ -1,18 +1,84 @@
 package config
 
+import "time"
+
 type Config struct {
-	WriteRegion string
-	ReadRegion  string
-	WriteRole   string
+	WriteRegions []WriteRegion
+	ReadRegion   string
+	// ReadRole is assumed for reads after every role of ReadRoleChain, in
+	// order. Reads use the default credentials when it is empty.
+	ReadRole      string
+	ReadRoleChain []string
 
 	StartAt int64
 
+	Concurrency int
+	// Parallelism is how many versions of a layer are enriched and downloaded at once.
+	Parallelism int
+
+	AlignVersions bool
+
+	Permissions Permissions
+
+	NameMapping NameMapping
+
+	MaxPackageSize  int64
+	DownloadTimeout time.Duration
+
+	// CacheDir keeps downloaded packages on disk between runs, up to CacheSize bytes.
+	CacheDir  string
+	CacheSize int64
+
+	// StagingThreshold is the package size above which versions are published
+	// through the staging bucket of a write region, when it has one.
+	StagingThreshold int64
+
+	// RoleOptions apply to every role that is assumed.
+	RoleOptions RoleOptions
+
+	Endpoints Endpoints
+
 	DryRun bool
 }
 
+type WriteRegion struct {
+	Region string
+	Role   string
+	// RoleChain are assumed in order before Role, e.g. a hub role that is the
+	// only one Role trusts.
+	RoleChain     []string
+	StagingBucket string
+}
+
+// Roles is the chain of roles assumed for the write region, ending with Role.
+func (w WriteRegion) Roles() []string {
+	return roleChain(w.RoleChain, w.Role)
+}
+
+// ReadRoles is the chain of roles assumed for reads, ending with ReadRole.
+func (c *Config) ReadRoles() []string {
+	return roleChain(c.ReadRoleChain, c.ReadRole)
+}
+
+// Permissions controls who can use the copied versions. An explicit set of
+// Public, OrganizationId or Accounts overrides Mirror, when nothing is set
+// the versions are made public.
+type Permissions struct {
+	Mirror         bool
+	Public         bool
+	OrganizationId string
+	Accounts       []string
+}
+
+func (p Permissions) Explicit() bool {
+	return p.Public || p.OrganizationId != "" || len(p.Accounts) > 0
+}
+
 func NewConfig(opts ...Option) *Config {
 	c := &Config{
-		DryRun: true,
+		Concurrency: 4,
+		Parallelism: 4,
+		DryRun:      true,
 	}
 
 	for _, opt := range opts {
@@ -24,9 +90,12 @@ func NewConfig(opts ...Option) *Config {
 
 type Option func(c *Config)
 
-func WithWriteRegion(region string) Option {
+func WithWriteRegion(region string, role string) Option {
 	return func(c *Config) {
-		c.WriteRegion = region
+		c.WriteRegions = append(c.WriteRegions, WriteRegion{
+			Region: region,
+			Role:   role,
+		})
 	}
 }
 
@@ -36,9 +105,22 @@ func WithReadRegion(region string) Option {
 	}
 }
 
-func WithWriteRole(role string) Option {
+// WithReadRole assumes role for reads, after every role of chain in order.
+func WithReadRole(role string, chain ...string) Option {
+	return func(c *Config) {
+		c.ReadRole = role
+		c.ReadRoleChain = chain
+	}
+}
+
+// WithWriteRoleChain sets the role chain of a write region added before it.
+func WithWriteRoleChain(region string, chain ...string) Option {
 	return func(c *Config) {
-		c.WriteRole = role
+		for i := range c.WriteRegions {
+			if c.WriteRegions[i].Region == region {
+				c.WriteRegions[i].RoleChain = chain
+			}
+		}
 	}
 }
 
@@ -46,4 +128,82 @@ func WithStartAt(startAt int64) Option {
 	return func(c *Config) {
 		c.StartAt = startAt
 	}
-}
\ No newline at end of file
+}
+
+func WithConcurrency(concurrency int) Option {
+	return func(c *Config) {
+		c.Concurrency = concurrency
+	}
+}
+
+func WithParallelism(parallelism int) Option {
+	return func(c *Config) {
+		c.Parallelism = parallelism
+	}
+}
+
+func WithAlignVersions(align bool) Option {
+	return func(c *Config) {
+		c.AlignVersions = align
+	}
+}
+
+func WithPermissions(permissions Permissions) Option {
+	return func(c *Config) {
+		c.Permissions = permissions
+	}
+}
+
+func WithNameMapping(mapping NameMapping) Option {
+	return func(c *Config) {
+		c.NameMapping = mapping
+	}
+}
+
+func WithMaxPackageSize(size int64) Option {
+	return func(c *Config) {
+		c.MaxPackageSize = size
+	}
+}
+
+// WithStagingBucket sets the staging bucket of a write region added before it.
+func WithStagingBucket(region string, bucket string) Option {
+	return func(c *Config) {
+		for i := range c.WriteRegions {
+			if c.WriteRegions[i].Region == region {
+				c.WriteRegions[i].StagingBucket = bucket
+			}
+		}
+	}
+}
+
+func WithStagingThreshold(size int64) Option {
+	return func(c *Config) {
+		c.StagingThreshold = size
+	}
+}
+
+func WithCacheDir(dir string, size int64) Option {
+	return func(c *Config) {
+		c.CacheDir = dir
+		c.CacheSize = size
+	}
+}
+
+func WithRoleOptions(options RoleOptions) Option {
+	return func(c *Config) {
+		c.RoleOptions = options
+	}
+}
+
+func WithEndpoints(endpoints Endpoints) Option {
+	return func(c *Config) {
+		c.Endpoints = endpoints
+	}
+}
+
+func WithDownloadTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.DownloadTimeout = timeout
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// Environment variables read by EndpointsFromEnv.
+const (
+	EnvEndpointLambda     = "BALANCE_ENDPOINT_LAMBDA"
+	EnvEndpointSTS        = "BALANCE_ENDPOINT_STS"
+	EnvEndpointS3         = "BALANCE_ENDPOINT_S3"
+	EnvEndpointSSM        = "BALANCE_ENDPOINT_SSM"
+	EnvCABundle           = "BALANCE_CA_BUNDLE"
+	EnvInsecureSkipVerify = "BALANCE_INSECURE_SKIP_VERIFY"
+)
+
+// Endpoints point the clients at other URLs than the AWS ones, e.g. a local
+// emulator. Empty endpoints keep the default of the service.
+type Endpoints struct {
+	Lambda string
+	STS    string
+	S3     string
+	SSM    string
+
+	// CABundle is a PEM file of certificate authorities trusted on top of the
+	// system ones.
+	CABundle string
+	// InsecureSkipVerify turns off TLS certificate verification, only meant
+	// for emulators with a self signed certificate.
+	InsecureSkipVerify bool
+}
+
+// EndpointsFromEnv reads the endpoints from the BALANCE_ENDPOINT_* and TLS
+// environment variables.
+func EndpointsFromEnv() (Endpoints, error) {
+	e := Endpoints{
+		Lambda:   os.Getenv(EnvEndpointLambda),
+		STS:      os.Getenv(EnvEndpointSTS),
+		S3:       os.Getenv(EnvEndpointS3),
+		SSM:      os.Getenv(EnvEndpointSSM),
+		CABundle: os.Getenv(EnvCABundle),
+	}
+
+	if value := os.Getenv(EnvInsecureSkipVerify); value != "" {
+		insecure, err := strconv.ParseBool(value)
+		if err != nil {
+			return e, fmt.Errorf("%s=%q is not a boolean", EnvInsecureSkipVerify, value)
+		}
+		e.InsecureSkipVerify = insecure
+	}
+
+	return e, e.Validate()
+}
+
+// Merge fills the fields unset in e from other.
+func (e Endpoints) Merge(other Endpoints) Endpoints {
+	or := func(a, b string) string {
+		if a != "" {
+			return a
+		}
+		return b
+	}
+
+	return Endpoints{
+		Lambda:             or(e.Lambda, other.Lambda),
+		STS:                or(e.STS, other.STS),
+		S3:                 or(e.S3, other.S3),
+		SSM:                or(e.SSM, other.SSM),
+		CABundle:           or(e.CABundle, other.CABundle),
+		InsecureSkipVerify: e.InsecureSkipVerify || other.InsecureSkipVerify,
+	}
+}
+
+// Validate checks every endpoint is an absolute http or https URL.
+func (e Endpoints) Validate() error {
+	var errs []error
+	for _, endpoint := range []struct{ service, url string }{
+		{"lambda", e.Lambda},
+		{"sts", e.STS},
+		{"s3", e.S3},
+		{"ssm", e.SSM},
+	} {
+		if endpoint.url == "" {
+			continue
+		}
+
+		u, err := url.Parse(endpoint.url)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%s endpoint %q is not an http or https URL", endpoint.service, endpoint.url))
+		}
+	}
+
+	return errors.Join(errs...)
+}
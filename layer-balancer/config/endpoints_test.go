@@ -0,0 +1,48 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+)
+
+func TestEndpoints(t *testing.T) {
+	t.Run("EndpointsFromEnv", func(t *testing.T) {
+		t.Setenv(config.EnvEndpointLambda, "http://localhost:9001")
+		t.Setenv(config.EnvEndpointSTS, "https://localhost:9002")
+		t.Setenv(config.EnvInsecureSkipVerify, "true")
+
+		endpoints, err := config.EndpointsFromEnv()
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		if endpoints.Lambda != "http://localhost:9001" || endpoints.STS != "https://localhost:9002" || endpoints.S3 != "" || !endpoints.InsecureSkipVerify {
+			t.Errorf("expected the environment to be read, got: %+v", endpoints)
+		}
+	})
+
+	t.Run("EndpointsFromEnv invalid", func(t *testing.T) {
+		t.Setenv(config.EnvInsecureSkipVerify, "maybe")
+		if _, err := config.EndpointsFromEnv(); err == nil {
+			t.Errorf("expected an invalid boolean error")
+		}
+
+		t.Setenv(config.EnvInsecureSkipVerify, "")
+		t.Setenv(config.EnvEndpointSSM, "localhost:9003")
+		if _, err := config.EndpointsFromEnv(); err == nil {
+			t.Errorf("expected an endpoint without a scheme to be invalid")
+		}
+	})
+
+	t.Run("Endpoints Merge", func(t *testing.T) {
+		endpoints := config.Endpoints{Lambda: "http://lambda:9001"}.Merge(config.Endpoints{
+			Lambda: "http://other:9001",
+			S3:     "http://s3:9000",
+		})
+
+		if endpoints.Lambda != "http://lambda:9001" || endpoints.S3 != "http://s3:9000" {
+			t.Errorf("expected only unset endpoints to be filled, got: %+v", endpoints)
+		}
+	})
+}
@@ -0,0 +1,555 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	accountPattern = regexp.MustCompile(`^\d{12}$`)
+	orgPattern     = regexp.MustCompile(`^o-[a-z0-9]{10,32}$`)
+	rolePattern    = regexp.MustCompile(`^arn:[a-z-]+:iam::(\d{12}):role/.+$`)
+)
+
+// Manifest describes which layers are copied from which source region to
+// which destination regions. It is read from YAML or JSON.
+type Manifest struct {
+	Sources      []ManifestSource      `yaml:"sources"`
+	Destinations []ManifestDestination `yaml:"destinations"`
+	Groups       []ManifestGroup       `yaml:"groups"`
+	Overrides    []ManifestOverride    `yaml:"overrides"`
+
+	Concurrency   int                 `yaml:"concurrency"`
+	Parallelism   int                 `yaml:"parallelism"`
+	AlignVersions bool                `yaml:"alignVersions"`
+	Permissions   ManifestPermissions `yaml:"permissions"`
+	NameMapping   ManifestNameMapping `yaml:"nameMapping"`
+
+	file string
+	node *yaml.Node
+}
+
+type ManifestSource struct {
+	Region    string   `yaml:"region"`
+	Role      string   `yaml:"role"`
+	RoleChain []string `yaml:"roleChain"`
+
+	node *yaml.Node
+}
+
+type ManifestDestination struct {
+	Region        string   `yaml:"region"`
+	Role          string   `yaml:"role"`
+	RoleChain     []string `yaml:"roleChain"`
+	Account       string   `yaml:"account"`
+	StagingBucket string   `yaml:"stagingBucket"`
+
+	node *yaml.Node
+}
+
+type ManifestGroup struct {
+	Name    string   `yaml:"name"`
+	Source  string   `yaml:"source"`
+	Regions []string `yaml:"regions"`
+	StartAt int64    `yaml:"startAt"`
+	Layers  []string `yaml:"layers"`
+
+	node *yaml.Node
+}
+
+type ManifestOverride struct {
+	Layer   string   `yaml:"layer"`
+	Regions []string `yaml:"regions"`
+	StartAt int64    `yaml:"startAt"`
+
+	node *yaml.Node
+}
+
+type ManifestPermissions struct {
+	Mirror         bool     `yaml:"mirror"`
+	Public         bool     `yaml:"public"`
+	OrganizationId string   `yaml:"organizationId"`
+	Accounts       []string `yaml:"accounts"`
+
+	node *yaml.Node
+}
+
+type ManifestNameMapping struct {
+	Table    map[string]string `yaml:"table"`
+	Pattern  string            `yaml:"pattern"`
+	Template string            `yaml:"template"`
+
+	node *yaml.Node
+}
+
+// Job is a set of layers that share a source region, destinations and start version.
+type Job struct {
+	Config *Config
+	Layers []string
+}
+
+type ManifestError struct {
+	File string
+	Line int
+	Key  string
+	Msg  string
+}
+
+func (e *ManifestError) Error() string {
+	return fmt.Sprintf("%s:%d: %s: %s", e.File, e.Line, e.Key, e.Msg)
+}
+
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseManifest(path, data)
+}
+
+// ParseManifest decodes and validates a manifest, file is only used in error messages.
+func ParseManifest(file string, data []byte) (*Manifest, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	if len(root.Content) == 0 {
+		return nil, &ManifestError{File: file, Line: 1, Key: "manifest", Msg: "is empty"}
+	}
+
+	m := &Manifest{file: file}
+	if err := root.Content[0].Decode(m); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *Manifest) UnmarshalYAML(node *yaml.Node) error {
+	type plain Manifest
+	if err := node.Decode((*plain)(m)); err != nil {
+		return err
+	}
+	m.node = node
+
+	return nil
+}
+
+func (s *ManifestSource) UnmarshalYAML(node *yaml.Node) error {
+	type plain ManifestSource
+	if err := node.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	s.node = node
+
+	return nil
+}
+
+func (d *ManifestDestination) UnmarshalYAML(node *yaml.Node) error {
+	type plain ManifestDestination
+	if err := node.Decode((*plain)(d)); err != nil {
+		return err
+	}
+	d.node = node
+
+	return nil
+}
+
+func (g *ManifestGroup) UnmarshalYAML(node *yaml.Node) error {
+	type plain ManifestGroup
+	if err := node.Decode((*plain)(g)); err != nil {
+		return err
+	}
+	g.node = node
+
+	return nil
+}
+
+func (p *ManifestPermissions) UnmarshalYAML(node *yaml.Node) error {
+	type plain ManifestPermissions
+	if err := node.Decode((*plain)(p)); err != nil {
+		return err
+	}
+	p.node = node
+
+	return nil
+}
+
+func (n *ManifestNameMapping) UnmarshalYAML(node *yaml.Node) error {
+	type plain ManifestNameMapping
+	if err := node.Decode((*plain)(n)); err != nil {
+		return err
+	}
+	n.node = node
+
+	return nil
+}
+
+func (o *ManifestOverride) UnmarshalYAML(node *yaml.Node) error {
+	type plain ManifestOverride
+	if err := node.Decode((*plain)(o)); err != nil {
+		return err
+	}
+	o.node = node
+
+	return nil
+}
+
+// Validate checks the manifest is complete and consistent, every problem found
+// is returned as a *ManifestError pointing at the offending key.
+func (m *Manifest) Validate() error {
+	var errs []error
+	fail := func(node *yaml.Node, key string, format string, args ...any) {
+		line := 0
+		if node != nil {
+			line = node.Line
+		}
+		errs = append(errs, &ManifestError{File: m.file, Line: line, Key: key, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	errs = append(errs, m.unknownKeys(m.node, "", "sources", "destinations", "groups", "overrides", "concurrency", "parallelism", "alignVersions", "permissions", "nameMapping")...)
+
+	if m.Concurrency < 0 {
+		fail(keyNode(m.node, "concurrency"), "concurrency", "must not be negative")
+	}
+
+	if m.Parallelism < 0 {
+		fail(keyNode(m.node, "parallelism"), "parallelism", "must not be negative")
+	}
+
+	errs = append(errs, m.unknownKeys(m.Permissions.node, "permissions", "mirror", "public", "organizationId", "accounts")...)
+
+	if org := m.Permissions.OrganizationId; org != "" && !orgPattern.MatchString(org) {
+		fail(keyNode(m.Permissions.node, "organizationId"), "permissions.organizationId", "%q is not an organization ID", org)
+	}
+
+	accounts := keyValue(m.Permissions.node, "accounts")
+	for i, a := range m.Permissions.Accounts {
+		if !accountPattern.MatchString(a) {
+			fail(itemNode(accounts, i), fmt.Sprintf("permissions.accounts[%d]", i), "%q is not a 12 digit account ID", a)
+		}
+	}
+
+	errs = append(errs, m.unknownKeys(m.NameMapping.node, "nameMapping", "table", "pattern", "template")...)
+
+	if n := m.NameMapping; n.Pattern != "" && n.Template == "" {
+		fail(n.node, "nameMapping.template", "is required with a pattern")
+	} else if n.Template != "" && n.Pattern == "" {
+		fail(n.node, "nameMapping.pattern", "is required with a template")
+	} else if err := m.nameMapping().Validate(); err != nil {
+		fail(keyNode(n.node, "pattern"), "nameMapping.pattern", "%v", err)
+	}
+
+	if len(m.Sources) == 0 {
+		fail(m.node, "sources", "at least one source region is required")
+	}
+
+	sources := map[string]bool{}
+	for i, s := range m.Sources {
+		key := fmt.Sprintf("sources[%d]", i)
+		errs = append(errs, m.unknownKeys(s.node, key, "region", "role", "roleChain")...)
+
+		if s.Region == "" {
+			fail(s.node, key+".region", "is required")
+		} else if sources[s.Region] {
+			fail(keyNode(s.node, "region"), key+".region", "%s is listed twice", s.Region)
+		}
+		sources[s.Region] = true
+
+		if s.Role != "" && !rolePattern.MatchString(s.Role) {
+			fail(keyNode(s.node, "role"), key+".role", "%q is not an IAM role ARN", s.Role)
+		}
+		m.checkRoleChain(fail, s.node, key, s.Role, s.RoleChain)
+	}
+
+	if len(m.Destinations) == 0 {
+		fail(m.node, "destinations", "at least one destination region is required")
+	}
+
+	destinations := map[string]bool{}
+	for i, d := range m.Destinations {
+		key := fmt.Sprintf("destinations[%d]", i)
+		errs = append(errs, m.unknownKeys(d.node, key, "region", "role", "roleChain", "account", "stagingBucket")...)
+
+		if d.Region == "" {
+			fail(d.node, key+".region", "is required")
+		} else if destinations[d.Region] {
+			fail(keyNode(d.node, "region"), key+".region", "%s is listed twice", d.Region)
+		}
+		destinations[d.Region] = true
+
+		if d.Account != "" && !accountPattern.MatchString(d.Account) {
+			fail(keyNode(d.node, "account"), key+".account", "%q is not a 12 digit account ID", d.Account)
+		}
+
+		if d.Role != "" {
+			match := rolePattern.FindStringSubmatch(d.Role)
+			if match == nil {
+				fail(keyNode(d.node, "role"), key+".role", "%q is not an IAM role ARN", d.Role)
+			} else if d.Account != "" && match[1] != d.Account {
+				fail(keyNode(d.node, "role"), key+".role", "role belongs to account %s, expected %s", match[1], d.Account)
+			}
+		}
+		m.checkRoleChain(fail, d.node, key, d.Role, d.RoleChain)
+	}
+
+	checkRegions := func(node *yaml.Node, key string, regions []string) {
+		seq := keyValue(node, "regions")
+		listed := map[string]bool{}
+		for i, r := range regions {
+			if !destinations[r] {
+				fail(itemNode(seq, i), fmt.Sprintf("%s.regions[%d]", key, i), "%s is not a destination", r)
+			} else if listed[r] {
+				fail(itemNode(seq, i), fmt.Sprintf("%s.regions[%d]", key, i), "%s is listed twice", r)
+			}
+			listed[r] = true
+		}
+	}
+
+	if len(m.Groups) == 0 {
+		fail(m.node, "groups", "at least one layer group is required")
+	}
+
+	layers := map[string]bool{}
+	for i, g := range m.Groups {
+		key := fmt.Sprintf("groups[%d]", i)
+		errs = append(errs, m.unknownKeys(g.node, key, "name", "source", "regions", "startAt", "layers")...)
+
+		if g.Source != "" && !sources[g.Source] {
+			fail(keyNode(g.node, "source"), key+".source", "%s is not a source", g.Source)
+		}
+
+		if g.StartAt < 0 {
+			fail(keyNode(g.node, "startAt"), key+".startAt", "must not be negative")
+		}
+
+		checkRegions(g.node, key, g.Regions)
+
+		if len(g.Layers) == 0 {
+			fail(g.node, key+".layers", "at least one layer is required")
+		}
+
+		seq := keyValue(g.node, "layers")
+		for j, l := range g.Layers {
+			if l == "" {
+				fail(itemNode(seq, j), fmt.Sprintf("%s.layers[%d]", key, j), "must not be empty")
+			} else if layers[l] {
+				fail(itemNode(seq, j), fmt.Sprintf("%s.layers[%d]", key, j), "%s is already part of a group", l)
+			}
+			layers[l] = true
+		}
+	}
+
+	overridden := map[string]bool{}
+	for i, o := range m.Overrides {
+		key := fmt.Sprintf("overrides[%d]", i)
+		errs = append(errs, m.unknownKeys(o.node, key, "layer", "regions", "startAt")...)
+
+		if !layers[o.Layer] {
+			fail(keyNode(o.node, "layer"), key+".layer", "%q is not part of any group", o.Layer)
+		} else if overridden[o.Layer] {
+			fail(keyNode(o.node, "layer"), key+".layer", "%s is overridden twice", o.Layer)
+		}
+		overridden[o.Layer] = true
+
+		if o.StartAt < 0 {
+			fail(keyNode(o.node, "startAt"), key+".startAt", "must not be negative")
+		}
+
+		checkRegions(o.node, key, o.Regions)
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkRoleChain reports hops that aren't role ARNs, and a chain without a
+// role to assume after it.
+func (m *Manifest) checkRoleChain(fail func(*yaml.Node, string, string, ...any), node *yaml.Node, key string, role string, chain []string) {
+	if len(chain) > 0 && role == "" {
+		fail(keyNode(node, "roleChain"), key+".role", "is required with a roleChain")
+	}
+
+	seq := keyValue(node, "roleChain")
+	for i, r := range chain {
+		if !rolePattern.MatchString(r) {
+			fail(itemNode(seq, i), fmt.Sprintf("%s.roleChain[%d]", key, i), "%q is not an IAM role ARN", r)
+		}
+	}
+}
+
+// Jobs expands the manifest into one job per distinct source, destination set
+// and start version.
+func (m *Manifest) Jobs() []Job {
+	roles := map[string]string{}
+	chains := map[string][]string{}
+	buckets := map[string]string{}
+	var allRegions []string
+	for _, d := range m.Destinations {
+		roles[d.Region] = d.Role
+		chains[d.Region] = d.RoleChain
+		buckets[d.Region] = d.StagingBucket
+		allRegions = append(allRegions, d.Region)
+	}
+
+	sources := map[string]ManifestSource{}
+	for _, s := range m.Sources {
+		sources[s.Region] = s
+	}
+
+	overrides := map[string]ManifestOverride{}
+	for _, o := range m.Overrides {
+		overrides[o.Layer] = o
+	}
+
+	var jobs []Job
+	index := map[string]int{}
+	for _, g := range m.Groups {
+		source := g.Source
+		if source == "" {
+			source = m.Sources[0].Region
+		}
+
+		for _, layer := range g.Layers {
+			regions, startAt := g.Regions, g.StartAt
+			if o, ok := overrides[layer]; ok {
+				if len(o.Regions) > 0 {
+					regions = o.Regions
+				}
+				if o.StartAt > 0 {
+					startAt = o.StartAt
+				}
+			}
+
+			if len(regions) == 0 {
+				regions = allRegions
+			}
+			if startAt == 0 {
+				startAt = 1
+			}
+
+			sorted := append([]string(nil), regions...)
+			sort.Strings(sorted)
+			key := fmt.Sprintf("%s|%s|%d", source, strings.Join(sorted, ","), startAt)
+
+			i, ok := index[key]
+			if !ok {
+				jobOpts := []Option{
+					WithReadRegion(source),
+					WithReadRole(sources[source].Role, sources[source].RoleChain...),
+					WithStartAt(startAt),
+					WithAlignVersions(m.AlignVersions),
+					WithNameMapping(m.nameMapping()),
+					WithPermissions(Permissions{
+						Mirror:         m.Permissions.Mirror,
+						Public:         m.Permissions.Public,
+						OrganizationId: m.Permissions.OrganizationId,
+						Accounts:       m.Permissions.Accounts,
+					}),
+				}
+				if m.Concurrency > 0 {
+					jobOpts = append(jobOpts, WithConcurrency(m.Concurrency))
+				}
+				if m.Parallelism > 0 {
+					jobOpts = append(jobOpts, WithParallelism(m.Parallelism))
+				}
+				for _, r := range regions {
+					jobOpts = append(jobOpts, WithWriteRegion(r, roles[r]), WithWriteRoleChain(r, chains[r]...), WithStagingBucket(r, buckets[r]))
+				}
+
+				i = len(jobs)
+				index[key] = i
+				jobs = append(jobs, Job{Config: NewConfig(jobOpts...)})
+			}
+
+			jobs[i].Layers = append(jobs[i].Layers, layer)
+		}
+	}
+
+	return jobs
+}
+
+func (m *Manifest) nameMapping() NameMapping {
+	return NameMapping{
+		Table:    m.NameMapping.Table,
+		Pattern:  m.NameMapping.Pattern,
+		Template: m.NameMapping.Template,
+	}
+}
+
+func (m *Manifest) unknownKeys(node *yaml.Node, prefix string, allowed ...string) []error {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var errs []error
+	for i := 0; i < len(node.Content); i += 2 {
+		k := node.Content[i]
+		known := false
+		for _, a := range allowed {
+			if k.Value == a {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			key := k.Value
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			errs = append(errs, &ManifestError{File: m.file, Line: k.Line, Key: key, Msg: "unknown key"})
+		}
+	}
+
+	return errs
+}
+
+// keyNode returns the node of key in a mapping, falling back to the mapping
+// itself so errors still point close to the problem.
+func keyNode(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return node
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+
+	return node
+}
+
+func keyValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func itemNode(seq *yaml.Node, i int) *yaml.Node {
+	if seq == nil || i >= len(seq.Content) {
+		return seq
+	}
+
+	return seq.Content[i]
+}
@@ -0,0 +1,210 @@
+package config_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+)
+
+const manifest = `
+sources:
+  - region: us-east-1
+destinations:
+  - region: eu-west-1
+    role: arn:aws:iam::123456789012:role/Balance
+    account: "123456789012"
+  - region: eu-west-2
+    role: arn:aws:iam::123456789012:role/Balance
+groups:
+  - name: python
+    layers:
+      - AWSLambdaPowertoolsPythonV3-python312-arm64
+      - AWSLambdaPowertoolsPythonV3-python312-x86_64
+overrides:
+  - layer: AWSLambdaPowertoolsPythonV3-python312-x86_64
+    regions: [eu-west-2]
+    startAt: 3
+`
+
+func TestParseManifest(t *testing.T) {
+	t.Run("ParseManifest", func(t *testing.T) {
+		m, err := config.ParseManifest("manifest.yaml", []byte(manifest))
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		jobs := m.Jobs()
+		if len(jobs) != 2 {
+			t.Fatalf("expected 2 jobs, got: %d", len(jobs))
+		}
+
+		if jobs[0].Config.ReadRegion != "us-east-1" || len(jobs[0].Config.WriteRegions) != 2 || jobs[0].Config.StartAt != 1 {
+			t.Errorf("wrong default job: %+v", jobs[0].Config)
+		}
+
+		if len(jobs[1].Config.WriteRegions) != 1 || jobs[1].Config.WriteRegions[0].Region != "eu-west-2" || jobs[1].Config.StartAt != 3 {
+			t.Errorf("override not applied: %+v", jobs[1].Config)
+		}
+
+		if jobs[1].Config.WriteRegions[0].Role != "arn:aws:iam::123456789012:role/Balance" {
+			t.Errorf("role not applied: %+v", jobs[1].Config.WriteRegions[0])
+		}
+	})
+
+	t.Run("ParseManifest JSON", func(t *testing.T) {
+		_, err := config.ParseManifest("manifest.json", []byte(`{
+  "sources": [{"region": "us-east-1"}],
+  "destinations": [{"region": "eu-west-1"}],
+  "groups": [{"layers": ["foo"]}]
+}`))
+		if err != nil {
+			t.Errorf("expected no errors: %v", err)
+		}
+	})
+
+	t.Run("ParseManifest staging bucket", func(t *testing.T) {
+		m, err := config.ParseManifest("manifest.yaml", []byte(strings.Replace(manifest, "  - region: eu-west-2\n", "  - region: eu-west-2\n    stagingBucket: staging-eu-west-2\n", 1)))
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		regions := m.Jobs()[0].Config.WriteRegions
+		if regions[0].StagingBucket != "" || regions[1].StagingBucket != "staging-eu-west-2" {
+			t.Errorf("staging bucket not applied: %+v", regions)
+		}
+	})
+
+	t.Run("ParseManifest role chains", func(t *testing.T) {
+		chained := strings.Replace(manifest, "  - region: us-east-1\n", `  - region: us-east-1
+    role: arn:aws:iam::210987654321:role/Read
+    roleChain: [arn:aws:iam::111111111111:role/Hub]
+`, 1)
+		chained = strings.Replace(chained, "  - region: eu-west-2\n", "  - region: eu-west-2\n    roleChain: [arn:aws:iam::111111111111:role/Hub]\n", 1)
+
+		m, err := config.ParseManifest("manifest.yaml", []byte(chained))
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		cfg := m.Jobs()[0].Config
+		if roles := cfg.ReadRoles(); len(roles) != 2 || roles[1] != "arn:aws:iam::210987654321:role/Read" {
+			t.Errorf("read role not applied: %v", roles)
+		}
+
+		if roles := cfg.WriteRegions[1].Roles(); len(roles) != 2 || roles[0] != "arn:aws:iam::111111111111:role/Hub" {
+			t.Errorf("write role chain not applied: %v", roles)
+		}
+
+		_, err = config.ParseManifest("manifest.yaml", []byte(strings.Replace(chained, "roleChain: [arn:aws:iam::111111111111:role/Hub]\n", "roleChain: [Hub]\n", 1)))
+		var mErr *config.ManifestError
+		if !errors.As(err, &mErr) || mErr.Key != "sources[0].roleChain[0]" {
+			t.Errorf("expected an invalid hop error, got: %v", err)
+		}
+	})
+
+	t.Run("ParseManifest name mapping", func(t *testing.T) {
+		m, err := config.ParseManifest("manifest.yaml", []byte(manifest+`nameMapping:
+  table:
+    AWSLambdaPowertoolsPythonV3-python312-arm64: corp-py312-arm
+  pattern: AWSLambdaPowertoolsPythonV3-(.+)
+  template: corp-$1
+`))
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		mapped, _ := m.Jobs()[0].Config.NameMapping.Map("AWSLambdaPowertoolsPythonV3-python312-arm64")
+		if mapped != "corp-py312-arm" {
+			t.Errorf("name mapping not applied: %s", mapped)
+		}
+
+		_, err = config.ParseManifest("manifest.yaml", []byte(manifest+"nameMapping:\n  pattern: \"(\"\n  template: x\n"))
+		var mErr *config.ManifestError
+		if !errors.As(err, &mErr) || mErr.Key != "nameMapping.pattern" || mErr.Line != 20 {
+			t.Errorf("expected an invalid pattern error, got: %v", err)
+		}
+	})
+
+	t.Run("ParseManifest validation errors", func(t *testing.T) {
+		bad := strings.Replace(manifest, "role: arn:aws:iam::123456789012:role/Balance\n    account", "role: not-a-role\n    account", 1)
+		bad = strings.Replace(bad, "regions: [eu-west-2]", "regions: [eu-west-3]", 1)
+		bad = strings.Replace(bad, "  - name: python", "  - name: python\n    typo: true", 1)
+
+		_, err := config.ParseManifest("manifest.yaml", []byte(bad))
+		if err == nil {
+			t.Fatalf("expected errors")
+		}
+
+		expected := map[string]int{
+			"destinations[0].role":    6,
+			"groups[0].typo":          12,
+			"overrides[0].regions[0]": 18,
+		}
+
+		joined, ok := err.(interface{ Unwrap() []error })
+		if !ok {
+			t.Fatalf("expected joined errors: %v", err)
+		}
+
+		for _, e := range joined.Unwrap() {
+			var mErr *config.ManifestError
+			if !errors.As(e, &mErr) {
+				t.Errorf("expected a ManifestError: %v", e)
+				continue
+			}
+
+			line, ok := expected[mErr.Key]
+			if !ok {
+				t.Errorf("unexpected error: %v", mErr)
+				continue
+			}
+
+			if mErr.Line != line || mErr.File != "manifest.yaml" {
+				t.Errorf("wrong position for %s: %v", mErr.Key, mErr)
+			}
+			delete(expected, mErr.Key)
+		}
+
+		if len(expected) > 0 {
+			t.Errorf("missing errors for %v: %v", expected, err)
+		}
+	})
+
+	t.Run("ParseManifest duplicate regions", func(t *testing.T) {
+		bad := strings.Replace(manifest, "  - name: python", "  - name: python\n    regions: [eu-west-1, eu-west-2, eu-west-1]", 1)
+		bad = strings.Replace(bad, "regions: [eu-west-2]", "regions: [eu-west-2, eu-west-2]", 1)
+
+		_, err := config.ParseManifest("manifest.yaml", []byte(bad))
+
+		expected := map[string]int{
+			"groups[0].regions[2]":    12,
+			"overrides[0].regions[1]": 18,
+		}
+
+		joined, ok := err.(interface{ Unwrap() []error })
+		if !ok {
+			t.Fatalf("expected joined errors: %v", err)
+		}
+
+		for _, e := range joined.Unwrap() {
+			var mErr *config.ManifestError
+			if !errors.As(e, &mErr) || mErr.Line != expected[mErr.Key] || !strings.Contains(mErr.Error(), "listed twice") {
+				t.Errorf("unexpected error: %v", e)
+				continue
+			}
+			delete(expected, mErr.Key)
+		}
+
+		if len(expected) > 0 {
+			t.Errorf("missing errors for %v: %v", expected, err)
+		}
+	})
+
+	t.Run("ParseManifest empty", func(t *testing.T) {
+		if _, err := config.ParseManifest("manifest.yaml", []byte("")); err == nil {
+			t.Errorf("expected errors")
+		}
+	})
+}
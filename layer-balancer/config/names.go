@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// NameMapping renames layers in the write regions. A name listed in Table is
+// renamed directly, otherwise a name fully matching Pattern is renamed to
+// Template, which refers to the captured parts as $1 or ${name}. Other names
+// are kept.
+type NameMapping struct {
+	Table    map[string]string
+	Pattern  string
+	Template string
+}
+
+func (m NameMapping) Empty() bool {
+	return len(m.Table) == 0 && m.Pattern == ""
+}
+
+func (m NameMapping) compile() (*regexp.Regexp, error) {
+	if m.Pattern == "" {
+		return nil, nil
+	}
+
+	if m.Template == "" {
+		return nil, fmt.Errorf("name pattern %q has no template", m.Pattern)
+	}
+
+	// checked on its own first so errors don't mention the anchors
+	if _, err := regexp.Compile(m.Pattern); err != nil {
+		return nil, fmt.Errorf("invalid name pattern: %w", err)
+	}
+
+	return regexp.MustCompile("^(?:" + m.Pattern + ")$"), nil
+}
+
+// Validate checks the pattern compiles and has a template.
+func (m NameMapping) Validate() error {
+	_, err := m.compile()
+	return err
+}
+
+// Map returns the write region name of the layer called name in the read region.
+func (m NameMapping) Map(name string) (string, error) {
+	if mapped, ok := m.Table[name]; ok {
+		return mapped, nil
+	}
+
+	re, err := m.compile()
+	if err != nil || re == nil {
+		return name, err
+	}
+
+	match := re.FindStringSubmatchIndex(name)
+	if match == nil {
+		return name, nil
+	}
+
+	mapped := string(re.ExpandString(nil, m.Template, name, match))
+	if mapped == "" {
+		return "", fmt.Errorf("name template %q maps %s to an empty name", m.Template, name)
+	}
+
+	return mapped, nil
+}
@@ -0,0 +1,55 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+)
+
+func TestNameMapping(t *testing.T) {
+	mapping := config.NameMapping{
+		Table: map[string]string{
+			"AWSLambdaPowertoolsTypeScriptV2": "corp-powertools-ts",
+		},
+		Pattern:  `AWSLambdaPowertoolsPythonV3-python(?P<version>\d+)-(x86|arm)(_64|64)`,
+		Template: "corp-powertools-py${version}-$2",
+	}
+
+	t.Run("NameMapping", func(t *testing.T) {
+		tests := map[string]string{
+			"AWSLambdaPowertoolsPythonV3-python312-x86_64":       "corp-powertools-py312-x86",
+			"AWSLambdaPowertoolsPythonV3-python313-arm64":        "corp-powertools-py313-arm",
+			"AWSLambdaPowertoolsTypeScriptV2":                    "corp-powertools-ts",
+			"AWSLambdaPowertoolsPythonV3-python312-x86_64-extra": "AWSLambdaPowertoolsPythonV3-python312-x86_64-extra",
+			"other": "other",
+		}
+
+		for name, expected := range tests {
+			mapped, err := mapping.Map(name)
+			if err != nil || mapped != expected {
+				t.Errorf("expected %s to map to %s, got: %s, %v", name, expected, mapped, err)
+			}
+		}
+	})
+
+	t.Run("NameMapping empty", func(t *testing.T) {
+		mapped, err := config.NameMapping{}.Map("foo")
+		if err != nil || mapped != "foo" {
+			t.Errorf("expected the name to be kept, got: %s, %v", mapped, err)
+		}
+	})
+
+	t.Run("NameMapping invalid", func(t *testing.T) {
+		if err := (config.NameMapping{Pattern: "(", Template: "x"}).Validate(); err == nil {
+			t.Errorf("expected an invalid pattern to fail")
+		}
+
+		if err := (config.NameMapping{Pattern: "foo"}).Validate(); err == nil {
+			t.Errorf("expected a pattern without template to fail")
+		}
+
+		if _, err := (config.NameMapping{Pattern: "foo", Template: "$2"}).Map("foo"); err == nil {
+			t.Errorf("expected an empty name to fail")
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const (
+	MinRoleDuration = 15 * time.Minute
+	MaxRoleDuration = 12 * time.Hour
+	// MaxChainedRoleDuration is the longest session of a role assumed with
+	// the credentials of another role.
+	MaxChainedRoleDuration = time.Hour
+)
+
+var (
+	sessionNamePattern = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+	externalIdPattern  = regexp.MustCompile(`^[\w+=,.@:/-]+$`)
+)
+
+// RoleOptions are passed to every sts:AssumeRole call, they show up in
+// CloudTrail along with the calls made with the role.
+type RoleOptions struct {
+	ExternalId     string
+	SessionName    string
+	Duration       time.Duration
+	Tags           map[string]string
+	SourceIdentity string
+}
+
+// Validate applies the limits of sts:AssumeRole, so a bad option fails before
+// any client is built.
+func (o RoleOptions) Validate() error {
+	if o.ExternalId != "" && (len(o.ExternalId) < 2 || len(o.ExternalId) > 1224 || !externalIdPattern.MatchString(o.ExternalId)) {
+		return fmt.Errorf("external ID must be 2 to 1224 characters of letters, digits and +=,.@:/-")
+	}
+
+	if o.SessionName != "" && !sessionNamePattern.MatchString(o.SessionName) {
+		return fmt.Errorf("role session name %q must be 2 to 64 characters of letters, digits and _+=,.@-", o.SessionName)
+	}
+
+	if o.SourceIdentity != "" && !sessionNamePattern.MatchString(o.SourceIdentity) {
+		return fmt.Errorf("source identity %q must be 2 to 64 characters of letters, digits and _+=,.@-", o.SourceIdentity)
+	}
+
+	if o.Duration != 0 && (o.Duration < MinRoleDuration || o.Duration > MaxRoleDuration) {
+		return fmt.Errorf("role duration %s must be between %s and %s", o.Duration, MinRoleDuration, MaxRoleDuration)
+	}
+
+	if len(o.Tags) > 50 {
+		return fmt.Errorf("at most 50 session tags can be set, got %d", len(o.Tags))
+	}
+	for key, value := range o.Tags {
+		if key == "" || len(key) > 128 || len(value) > 256 {
+			return fmt.Errorf("session tag %q=%q must have a key of 1 to 128 and a value of up to 256 characters", key, value)
+		}
+	}
+
+	return nil
+}
+
+// ValidateRoles checks the role options and every role chain, so a bad ARN or
+// a session too long to be chained fails before any client is built.
+func (c *Config) ValidateRoles() error {
+	if err := c.RoleOptions.Validate(); err != nil {
+		return err
+	}
+
+	var errs []error
+	check := func(side string, role string, chain []string) {
+		if len(chain) > 0 && role == "" {
+			errs = append(errs, fmt.Errorf("%s role chain requires a role to assume after it", side))
+		}
+		if len(chain) > 0 && c.RoleOptions.Duration > MaxChainedRoleDuration {
+			errs = append(errs, fmt.Errorf("%s role chain: role duration %s is above the %s limit of chained roles", side, c.RoleOptions.Duration, MaxChainedRoleDuration))
+		}
+
+		for i, r := range roleChain(chain, role) {
+			if !rolePattern.MatchString(r) {
+				errs = append(errs, fmt.Errorf("%s role chain hop %d: %q is not an IAM role ARN", side, i+1, r))
+			}
+		}
+	}
+
+	check("read", c.ReadRole, c.ReadRoleChain)
+	for _, w := range c.WriteRegions {
+		check("write "+w.Region, w.Role, w.RoleChain)
+	}
+
+	return errors.Join(errs...)
+}
+
+func roleChain(chain []string, role string) []string {
+	if role == "" {
+		return nil
+	}
+
+	return append(append([]string(nil), chain...), role)
+}
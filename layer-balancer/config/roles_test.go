@@ -0,0 +1,75 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+)
+
+func TestRoleOptions(t *testing.T) {
+	t.Run("RoleOptions valid", func(t *testing.T) {
+		options := config.RoleOptions{
+			ExternalId:     "powertools-release",
+			SessionName:    "1234567890-AWSLambdaPowertoolsPythonV3",
+			Duration:       time.Hour,
+			Tags:           map[string]string{"run": "1234567890", "layer": "AWSLambdaPowertoolsPythonV3"},
+			SourceIdentity: "release-bot",
+		}
+
+		if err := options.Validate(); err != nil {
+			t.Errorf("expected the options to be valid: %v", err)
+		}
+
+		if err := (config.RoleOptions{}).Validate(); err != nil {
+			t.Errorf("expected no options to be valid: %v", err)
+		}
+	})
+
+	t.Run("RoleOptions invalid", func(t *testing.T) {
+		tests := map[string]config.RoleOptions{
+			"session name":    {SessionName: "run 1234"},
+			"long session":    {SessionName: strings.Repeat("a", 65)},
+			"external ID":     {ExternalId: "a"},
+			"source identity": {SourceIdentity: "bot!"},
+			"short duration":  {Duration: time.Minute},
+			"long duration":   {Duration: 13 * time.Hour},
+			"empty tag key":   {Tags: map[string]string{"": "value"}},
+		}
+
+		for name, options := range tests {
+			if err := options.Validate(); err == nil {
+				t.Errorf("expected %s to be invalid: %+v", name, options)
+			}
+		}
+	})
+
+	t.Run("ValidateRoles", func(t *testing.T) {
+		hub := "arn:aws:iam::111111111111:role/Hub"
+		cfg := config.NewConfig(
+			config.WithReadRole("arn:aws:iam::210987654321:role/Read", hub),
+			config.WithWriteRegion("eu-west-1", "arn:aws:iam::012345678912:role/Balance"),
+			config.WithWriteRoleChain("eu-west-1", hub),
+		)
+
+		if err := cfg.ValidateRoles(); err != nil {
+			t.Errorf("expected the chains to be valid: %v", err)
+		}
+
+		cfg.RoleOptions.Duration = 2 * time.Hour
+		if err := cfg.ValidateRoles(); err == nil || !strings.Contains(err.Error(), "chained roles") {
+			t.Errorf("expected the duration to be too long for a chain, got: %v", err)
+		}
+
+		cfg = config.NewConfig(config.WithReadRole("", hub))
+		if err := cfg.ValidateRoles(); err == nil {
+			t.Errorf("expected a chain without a role to be invalid")
+		}
+
+		cfg = config.NewConfig(config.WithWriteRegion("eu-west-1", "arn:aws:iam::012345678912:role/Balance"), config.WithWriteRoleChain("eu-west-1", "Hub"))
+		if err := cfg.ValidateRoles(); err == nil || !strings.Contains(err.Error(), "write eu-west-1 role chain hop 1") {
+			t.Errorf("expected the hop to be named, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package layers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+var (
+	ErrDestinationAhead = errors.New("destination layer is ahead of the source")
+	ErrVersionMismatch  = errors.New("destination version doesn't match the source version")
+)
+
+const placeholderDescription = "Placeholder to keep version numbers aligned, it will be deleted"
+
+// FindGaps returns the version numbers missing from a sorted source history,
+// counting from version 1.
+func FindGaps(versions []*lambda.GetLayerVersionByArnOutput) []int64 {
+	var gaps []int64
+	next := int64(1)
+	for _, v := range versions {
+		for ; next < v.Version; next++ {
+			gaps = append(gaps, next)
+		}
+		next = v.Version + 1
+	}
+
+	return gaps
+}
+
+// LatestVersion returns the highest version number in a listing, or 0 when empty.
+func LatestVersion(listVersions []types.LayerVersionsListItem) int64 {
+	var latest int64
+	for _, v := range listVersions {
+		if v.Version > latest {
+			latest = v.Version
+		}
+	}
+
+	return latest
+}
+
+// aligner publishes placeholder versions in the destination so the next real
+// version gets the same number as in the source, placeholders are deleted by cleanup.
+type aligner struct {
+	dest      Destination
+	layerName string
+	dryRun    bool
+
+	next         int64
+	placeholders []int64
+}
+
+func newAligner(dest Destination, layerName string, dryRun bool, latest int64) *aligner {
+	return &aligner{
+		dest:      dest,
+		layerName: layerName,
+		dryRun:    dryRun,
+		next:      latest + 1,
+	}
+}
+
+func (a *aligner) alignTo(ctx context.Context, version int64) error {
+	if a.next > version {
+		return fmt.Errorf("%w: next version of %s in %s is %d, source version is %d", ErrDestinationAhead, a.layerName, a.dest.Region, a.next, version)
+	}
+
+	for a.next < version {
+		log.Printf("Publishing placeholder: %s:%d in %s", a.layerName, a.next, a.dest.Region)
+
+		if a.dryRun {
+			a.next++
+			continue
+		}
+
+		zip, err := placeholderPackage()
+		if err != nil {
+			return err
+		}
+
+		out, err := a.dest.Client.PublishLayerVersion(ctx, &lambda.PublishLayerVersionInput{
+			Content: &types.LayerVersionContentInput{
+				ZipFile: zip,
+			},
+			LayerName:   awsSDK.String(a.layerName),
+			Description: awsSDK.String(placeholderDescription),
+		})
+		if err != nil {
+			return err
+		}
+
+		// a placeholder in the slot of version means versions after the latest
+		// one were deleted, their numbers are never given out again
+		a.placeholders = append(a.placeholders, out.Version)
+		if out.Version >= version {
+			return fmt.Errorf("%w: placeholder for %s in %s got version %d, source version is %d", ErrDestinationAhead, a.layerName, a.dest.Region, out.Version, version)
+		}
+
+		a.next = out.Version + 1
+	}
+
+	return nil
+}
+
+// published checks the number a copy got. Without a placeholder before it,
+// the copy is the first to find out the destination is ahead because of
+// deleted versions, a misnumbered copy is deleted again.
+func (a *aligner) published(ctx context.Context, version int64, out *lambda.PublishLayerVersionOutput) error {
+	if out == nil {
+		a.next = version + 1
+		return nil
+	}
+
+	if out.Version != version {
+		log.Printf("Deleting misnumbered copy: %s:%d in %s", a.layerName, out.Version, a.dest.Region)
+
+		_, err := a.dest.Client.DeleteLayerVersion(ctx, &lambda.DeleteLayerVersionInput{
+			LayerName:     awsSDK.String(a.layerName),
+			VersionNumber: awsSDK.Int64(out.Version),
+		})
+		if err != nil {
+			err = fmt.Errorf("unable to delete %s:%d in %s: %w", a.layerName, out.Version, a.dest.Region, err)
+		}
+
+		sentinel := ErrVersionMismatch
+		if out.Version > version {
+			sentinel = ErrDestinationAhead
+		}
+
+		return errors.Join(fmt.Errorf("%w: %s in %s published as %d, source version is %d", sentinel, a.layerName, a.dest.Region, out.Version, version), err)
+	}
+
+	a.next = out.Version + 1
+
+	return nil
+}
+
+func (a *aligner) cleanup(ctx context.Context) error {
+	var failed []string
+	for _, v := range a.placeholders {
+		log.Printf("Deleting placeholder: %s:%d in %s", a.layerName, v, a.dest.Region)
+
+		if _, err := a.dest.Client.DeleteLayerVersion(ctx, &lambda.DeleteLayerVersionInput{
+			LayerName:     awsSDK.String(a.layerName),
+			VersionNumber: awsSDK.Int64(v),
+		}); err != nil {
+			failed = append(failed, strconv.FormatInt(v, 10))
+		}
+	}
+	a.placeholders = nil
+
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to delete placeholder versions %s of %s in %s", strings.Join(failed, ", "), a.layerName, a.dest.Region)
+	}
+
+	return nil
+}
+
+func placeholderPackage() ([]byte, error) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+
+	f, err := w.Create("PLACEHOLDER")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Write([]byte(placeholderDescription)); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,234 @@
+package layers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestFindGaps(t *testing.T) {
+	gaps := layers.FindGaps([]*lambda.GetLayerVersionByArnOutput{
+		{Version: 2},
+		{Version: 3},
+		{Version: 6},
+	})
+
+	if len(gaps) != 3 || gaps[0] != 1 || gaps[1] != 4 || gaps[2] != 5 {
+		t.Errorf("wrong gaps returned, got: %v", gaps)
+	}
+}
+
+func TestAlignVersions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`OK`))
+	}))
+	defer server.Close()
+
+	newVersion := func(version int64) *lambda.GetLayerVersionByArnOutput {
+		return &lambda.GetLayerVersionByArnOutput{
+			Version:         version,
+			LayerArn:        awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo"),
+			LayerVersionArn: awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo:" + strconv.FormatInt(version, 10)),
+			Content: &types.LayerVersionContentOutput{
+				Location: awsSDK.String(server.URL),
+			},
+		}
+	}
+
+	// removed versions were published after latest and deleted again, Lambda
+	// doesn't hand out their numbers anymore
+	newClient := func(latest int64, removed int64, published *[]int64, deleted *[]int64) *FakeClient {
+		next := latest + removed + 1
+		return &FakeClient{
+			ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+				if latest == 0 {
+					return &lambda.ListLayerVersionsOutput{}, nil
+				}
+
+				return &lambda.ListLayerVersionsOutput{
+					LayerVersions: []types.LayerVersionsListItem{
+						{
+							Version:         latest,
+							LayerVersionArn: awsSDK.String("arn:aws:lambda:eu-west-1:012345678912:layer:foo:" + strconv.FormatInt(latest, 10)),
+						},
+					},
+				}, nil
+			},
+			GetLayerVersionByArnFn: func(ctx context.Context, params *lambda.GetLayerVersionByArnInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionByArnOutput, error) {
+				return &lambda.GetLayerVersionByArnOutput{
+					Version:         latest,
+					LayerVersionArn: params.Arn,
+					Content: &types.LayerVersionContentOutput{
+						CodeSha256: awsSDK.String("unrelated"),
+					},
+				}, nil
+			},
+			PublishLayerVersionFn: func(ctx context.Context, params *lambda.PublishLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishLayerVersionOutput, error) {
+				*published = append(*published, next)
+				next++
+				return &lambda.PublishLayerVersionOutput{
+					Version: next - 1,
+				}, nil
+			},
+			AddLayerVersionPermissionFn: func(ctx context.Context, params *lambda.AddLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.AddLayerVersionPermissionOutput, error) {
+				return nil, nil
+			},
+			DeleteLayerVersionFn: func(ctx context.Context, params *lambda.DeleteLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.DeleteLayerVersionOutput, error) {
+				*deleted = append(*deleted, *params.VersionNumber)
+				return nil, nil
+			},
+		}
+	}
+
+	t.Run("AlignVersions placeholders", func(t *testing.T) {
+		var published, deleted []int64
+
+		cfg := config.NewConfig(config.WithStartAt(1), config.WithAlignVersions(true))
+		cfg.DryRun = false
+
+		balancer := &layers.Balancer{
+			Config: cfg,
+			Destinations: []layers.Destination{
+				{Region: "eu-west-1", Client: newClient(0, 0, &published, &deleted)},
+			},
+		}
+
+		results := balancer.BalanceRegions(context.TODO(), "foo", []*lambda.GetLayerVersionByArnOutput{
+			newVersion(1),
+			newVersion(4),
+		})
+
+		if results[0].Err != nil {
+			t.Fatalf("expected no errors: %v", results[0].Err)
+		}
+
+		if len(published) != 4 {
+			t.Errorf("expected 4 published versions, got: %v", published)
+		}
+
+		if len(deleted) != 2 || deleted[0] != 2 || deleted[1] != 3 {
+			t.Errorf("expected placeholders 2 and 3 to be deleted, got: %v", deleted)
+		}
+	})
+
+	t.Run("AlignVersions destination ahead", func(t *testing.T) {
+		var published, deleted []int64
+
+		cfg := config.NewConfig(config.WithStartAt(2), config.WithAlignVersions(true))
+		cfg.DryRun = false
+
+		balancer := &layers.Balancer{
+			Config: cfg,
+			Destinations: []layers.Destination{
+				{Region: "eu-west-1", Client: newClient(3, 0, &published, &deleted)},
+			},
+		}
+
+		results := balancer.BalanceRegions(context.TODO(), "foo", []*lambda.GetLayerVersionByArnOutput{
+			newVersion(1),
+			newVersion(2),
+		})
+
+		if !errors.Is(results[0].Err, layers.ErrDestinationAhead) {
+			t.Errorf("expected destination ahead error, got: %v", results[0].Err)
+		}
+
+		if len(published) != 0 {
+			t.Errorf("expected nothing to be published, got: %v", published)
+		}
+	})
+
+	t.Run("AlignVersions deleted destination versions", func(t *testing.T) {
+		var published, deleted []int64
+
+		cfg := config.NewConfig(config.WithStartAt(4), config.WithAlignVersions(true))
+		cfg.DryRun = false
+
+		balancer := &layers.Balancer{
+			Config: cfg,
+			Destinations: []layers.Destination{
+				{Region: "eu-west-1", Client: newClient(3, 2, &published, &deleted)},
+			},
+		}
+
+		results := balancer.BalanceRegions(context.TODO(), "foo", []*lambda.GetLayerVersionByArnOutput{
+			newVersion(4),
+		})
+
+		if !errors.Is(results[0].Err, layers.ErrDestinationAhead) {
+			t.Errorf("expected destination ahead error, got: %v", results[0].Err)
+		}
+
+		if len(published) != 1 || len(deleted) != 1 || deleted[0] != 6 {
+			t.Errorf("expected the misnumbered copy 6 to be deleted, got: %v published, %v deleted", published, deleted)
+		}
+
+		if results[0].Copied != 0 || len(results[0].Versions) != 0 {
+			t.Errorf("expected the misnumbered copy not to be reported, got: %+v", results[0])
+		}
+	})
+
+	t.Run("AlignVersions deleted destination versions with a gap", func(t *testing.T) {
+		var published, deleted []int64
+
+		cfg := config.NewConfig(config.WithStartAt(5), config.WithAlignVersions(true))
+		cfg.DryRun = false
+
+		balancer := &layers.Balancer{
+			Config: cfg,
+			Destinations: []layers.Destination{
+				{Region: "eu-west-1", Client: newClient(3, 2, &published, &deleted)},
+			},
+		}
+
+		results := balancer.BalanceRegions(context.TODO(), "foo", []*lambda.GetLayerVersionByArnOutput{
+			newVersion(5),
+		})
+
+		if !errors.Is(results[0].Err, layers.ErrDestinationAhead) {
+			t.Errorf("expected destination ahead error, got: %v", results[0].Err)
+		}
+
+		// only the placeholder is published, the real version never is
+		if len(published) != 1 || len(deleted) != 1 || deleted[0] != 6 {
+			t.Errorf("expected only placeholder 6 to be published and deleted, got: %v published, %v deleted", published, deleted)
+		}
+	})
+
+	t.Run("AlignVersions failed placeholder cleanup", func(t *testing.T) {
+		var published, deleted []int64
+
+		cfg := config.NewConfig(config.WithStartAt(5), config.WithAlignVersions(true))
+		cfg.DryRun = false
+
+		client := newClient(3, 2, &published, &deleted)
+		client.DeleteLayerVersionFn = func(ctx context.Context, params *lambda.DeleteLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.DeleteLayerVersionOutput, error) {
+			return nil, errors.New("throttled")
+		}
+
+		balancer := &layers.Balancer{
+			Config: cfg,
+			Destinations: []layers.Destination{
+				{Region: "eu-west-1", Client: client},
+			},
+		}
+
+		results := balancer.BalanceRegions(context.TODO(), "foo", []*lambda.GetLayerVersionByArnOutput{
+			newVersion(5),
+		})
+
+		if !errors.Is(results[0].Err, layers.ErrDestinationAhead) || !strings.Contains(results[0].Err.Error(), "unable to delete placeholder versions 6") {
+			t.Errorf("expected the leftover placeholder to be reported with the alignment error, got: %v", results[0].Err)
+		}
+	})
+}
@@ -0,0 +1,525 @@
+package layers
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// ArchivedVersion is the JSON sidecar stored next to every exported package.
+// Permissions is only recorded when the export mirrors policies, an empty list
+// then means the version was private.
+type ArchivedVersion struct {
+	LayerName               string               `json:"layerName"`
+	Version                 int64                `json:"version"`
+	LayerVersionArn         string               `json:"layerVersionArn"`
+	Description             string               `json:"description,omitempty"`
+	CompatibleRuntimes      []types.Runtime      `json:"compatibleRuntimes,omitempty"`
+	CompatibleArchitectures []types.Architecture `json:"compatibleArchitectures,omitempty"`
+	LicenseInfo             string               `json:"licenseInfo,omitempty"`
+	CodeSha256              string               `json:"codeSha256"`
+	CodeSize                int64                `json:"codeSize"`
+	Permissions             []Permission         `json:"permissions"`
+}
+
+// Output turns the sidecar back into the version it was exported from, without
+// a package location.
+func (a ArchivedVersion) Output() *lambda.GetLayerVersionByArnOutput {
+	return &lambda.GetLayerVersionByArnOutput{
+		Version:                 a.Version,
+		LayerArn:                awsSDK.String(layerArn(a.LayerVersionArn)),
+		LayerVersionArn:         awsSDK.String(a.LayerVersionArn),
+		Description:             optionalString(a.Description),
+		CompatibleRuntimes:      a.CompatibleRuntimes,
+		CompatibleArchitectures: a.CompatibleArchitectures,
+		LicenseInfo:             optionalString(a.LicenseInfo),
+		Content: &types.LayerVersionContentOutput{
+			CodeSha256: awsSDK.String(a.CodeSha256),
+			CodeSize:   a.CodeSize,
+		},
+	}
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return awsSDK.String(s)
+}
+
+// archivePath is where a version is stored in an archive, ext is zip or json.
+func archivePath(layerName string, version int64, ext string) string {
+	return path.Join(layerName, fmt.Sprintf("%d.%s", version, ext))
+}
+
+// ArchiveWriter stores exported files, in a directory or a tar file.
+type ArchiveWriter interface {
+	// WriteFile stores the size bytes of r as name.
+	WriteFile(name string, r io.Reader, size int64) error
+	Close() error
+	// Abort removes what was written so far, so a failed export doesn't
+	// leave an archive that looks complete.
+	Abort() error
+}
+
+// NewArchiveWriter writes a tar file when path ends in .tar, otherwise it
+// writes into the directory path.
+func NewArchiveWriter(path string) (ArchiveWriter, error) {
+	if strings.HasSuffix(path, ".tar") {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return &tarArchiveWriter{f: f, tw: tar.NewWriter(f)}, nil
+	}
+
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &dirArchiveWriter{dir: path}, nil
+}
+
+type dirArchiveWriter struct {
+	dir     string
+	written []string
+}
+
+func (w *dirArchiveWriter) WriteFile(name string, r io.Reader, size int64) error {
+	target := filepath.Join(w.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	w.written = append(w.written, target)
+
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n != size {
+		err = fmt.Errorf("%s: wrote %d bytes, expected %d", name, n, size)
+	}
+
+	return err
+}
+
+func (w *dirArchiveWriter) Close() error {
+	return nil
+}
+
+// Abort only removes the files it wrote, the directory may hold others.
+func (w *dirArchiveWriter) Abort() error {
+	var errs []error
+	for _, file := range w.written {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+type tarArchiveWriter struct {
+	f  *os.File
+	tw *tar.Writer
+}
+
+func (w *tarArchiveWriter) WriteFile(name string, r io.Reader, size int64) error {
+	err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0o644,
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w.tw, r)
+	return err
+}
+
+func (w *tarArchiveWriter) Close() error {
+	err := w.tw.Close()
+	if closeErr := w.f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func (w *tarArchiveWriter) Abort() error {
+	w.f.Close()
+	return os.Remove(w.f.Name())
+}
+
+// Export writes every version of layerName from StartAt on to w, recording
+// the policy of each version when Config.Permissions.Mirror is set.
+func (b *Balancer) Export(ctx context.Context, layerName string, w ArchiveWriter) (int, error) {
+	listVersions, err := DiscoverVersions(ctx, b.ReadClient, layerName)
+	if err != nil {
+		return 0, err
+	}
+
+	versions, err := EnrichVersionsConcurrently(ctx, b.ReadClient, listVersions, b.Config.Parallelism)
+	if err != nil {
+		return 0, err
+	}
+
+	var pending []*lambda.GetLayerVersionByArnOutput
+	for _, v := range versions {
+		if v.Version >= b.Config.StartAt {
+			pending = append(pending, v)
+		}
+	}
+
+	// every package is written once, so they aren't kept for other regions
+	packages := startPipeline(ctx, pending, b.Config.Parallelism, nil, newCopyOptions(WithPackageCache(b.Cache), WithDownloader(b.Downloader), WithReadClient(b.ReadClient)))
+	defer packages.stop()
+
+	for i, v := range pending {
+		log.Printf("Exporting: %s", awsSDK.ToString(v.LayerVersionArn))
+
+		pkg, err := packages.next(ctx, i)
+		if err != nil {
+			return i, err
+		}
+
+		if err := writePackage(w, archivePath(layerName, v.Version, "zip"), pkg); err != nil {
+			return i, err
+		}
+
+		sidecar := ArchivedVersion{
+			LayerName:               layerName,
+			Version:                 v.Version,
+			LayerVersionArn:         awsSDK.ToString(v.LayerVersionArn),
+			Description:             awsSDK.ToString(v.Description),
+			CompatibleRuntimes:      v.CompatibleRuntimes,
+			CompatibleArchitectures: v.CompatibleArchitectures,
+			LicenseInfo:             awsSDK.ToString(v.LicenseInfo),
+			CodeSha256:              awsSDK.ToString(v.Content.CodeSha256),
+			CodeSize:                v.Content.CodeSize,
+		}
+
+		if b.Config.Permissions.Mirror {
+			if sidecar.Permissions, err = b.permissions(ctx, v); err != nil {
+				return i, err
+			}
+			if sidecar.Permissions == nil {
+				sidecar.Permissions = []Permission{}
+			}
+		}
+
+		data, err := json.MarshalIndent(sidecar, "", "  ")
+		if err != nil {
+			return i, err
+		}
+
+		if err := w.WriteFile(archivePath(layerName, v.Version, "json"), bytes.NewReader(data), int64(len(data))); err != nil {
+			return i, err
+		}
+	}
+
+	return len(pending), nil
+}
+
+// writePackage streams pkg into w and removes it.
+func writePackage(w ArchiveWriter, name string, pkg *Package) error {
+	defer pkg.Remove()
+
+	f, err := os.Open(pkg.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return w.WriteFile(name, f, pkg.Size)
+}
+
+var ErrNoPermissions = errors.New("archive has no recorded permissions, export it with -mirror-policy")
+
+// Archive is an export opened for import. It is also the PackageCache packages
+// are published from, verifying each against its CodeSha256.
+type Archive struct {
+	Dir string
+
+	packages map[string]string
+	cleanup  func()
+}
+
+// OpenArchive opens an export directory, or extracts a tar file to a
+// temporary directory that Close removes again.
+func OpenArchive(path string) (*Archive, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{Dir: path, cleanup: func() {}}
+	if !info.IsDir() {
+		dir, err := os.MkdirTemp("", "layer-archive-*")
+		if err != nil {
+			return nil, err
+		}
+
+		a.Dir = dir
+		a.cleanup = func() { os.RemoveAll(dir) }
+
+		if err := extractTar(path, dir); err != nil {
+			a.Close()
+			return nil, err
+		}
+	}
+
+	if err := a.index(); err != nil {
+		a.Close()
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *Archive) Close() error {
+	a.cleanup()
+	return nil
+}
+
+// index maps the CodeSha256 of every sidecar to its package.
+func (a *Archive) index() error {
+	a.packages = map[string]string{}
+
+	layerNames, err := a.Layers()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range layerNames {
+		versions, err := a.Versions(name)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range versions {
+			a.packages[v.CodeSha256] = filepath.Join(a.Dir, filepath.FromSlash(archivePath(name, v.Version, "zip")))
+		}
+	}
+
+	return nil
+}
+
+func (a *Archive) Layers() ([]string, error) {
+	entries, err := os.ReadDir(a.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// Versions returns the archived versions of layerName in ascending order.
+func (a *Archive) Versions(layerName string) ([]ArchivedVersion, error) {
+	dir := filepath.Join(a.Dir, layerName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []ArchivedVersion
+	for _, e := range entries {
+		number, found := strings.CutSuffix(e.Name(), ".json")
+		if _, err := strconv.ParseInt(number, 10, 64); !found || err != nil {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var v ArchivedVersion
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Join(dir, e.Name()), err)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, number+".zip")); err != nil {
+			return nil, fmt.Errorf("%s version %d has no package: %w", layerName, v.Version, err)
+		}
+
+		versions = append(versions, v)
+	}
+
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%s: %w", layerName, ErrNoVersions)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+
+	return versions, nil
+}
+
+func (a *Archive) Get(key string) ([]byte, bool) {
+	file, ok := a.packages[key]
+	if !ok {
+		return nil, false
+	}
+
+	zip, err := os.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+
+	sum := sha256.Sum256(zip)
+	if base64.StdEncoding.EncodeToString(sum[:]) != key {
+		log.Printf("Archived package doesn't match its CodeSha256: %s", file)
+		return nil, false
+	}
+
+	return zip, true
+}
+
+// Open returns the archived package of key, the reader verifies it.
+func (a *Archive) Open(key string) (io.ReadCloser, bool) {
+	file, ok := a.packages[key]
+	if !ok {
+		return nil, false
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, false
+	}
+
+	return f, true
+}
+
+// Put does nothing, archives are read only.
+func (a *Archive) Put(key string, zip []byte) {}
+
+// PutFile does nothing, archives are read only.
+func (a *Archive) PutFile(key string, path string) {}
+
+// Import publishes an archived layer history to every destination with the
+// same semantics as Balance. It works on a copy of b that publishes from the
+// archive, so packages are never downloaded and mirrored permissions come from
+// the sidecars, b itself is left as it is.
+func (b *Balancer) Import(ctx context.Context, archive *Archive, layerName string) ([]RegionResult, error) {
+	archived, err := archive.Versions(layerName)
+	if err != nil {
+		return nil, err
+	}
+
+	destName, err := b.DestinationName(layerName)
+	if err != nil {
+		return nil, err
+	}
+
+	mirror := b.Config.Permissions.Mirror && !b.Config.Permissions.Explicit()
+
+	imported := &Balancer{
+		Config:       b.Config,
+		Destinations: b.Destinations,
+		Cache:        archive,
+		Downloader:   b.Downloader,
+		Packages:     b.Packages,
+		policies:     map[string]*policyEntry{},
+	}
+
+	var versions []*lambda.GetLayerVersionByArnOutput
+	for _, v := range archived {
+		if mirror {
+			if v.Permissions == nil {
+				return nil, fmt.Errorf("%s: %w", v.LayerVersionArn, ErrNoPermissions)
+			}
+
+			imported.policies[v.LayerVersionArn] = knownPolicy(v.Permissions)
+		}
+
+		versions = append(versions, v.Output())
+	}
+
+	log.Printf("Found %d archived versions of %s", len(versions), layerName)
+
+	return imported.BalanceRegions(ctx, destName, versions), nil
+}
+
+// extractTar unpacks the regular files of a tar file into dir.
+func extractTar(file string, dir string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("%s: invalid entry %q", file, hdr.Name)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+
+		out, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(out, tr)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
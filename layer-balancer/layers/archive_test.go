@@ -0,0 +1,219 @@
+package layers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestArchive(t *testing.T) {
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		downloads++
+		rw.Write([]byte("package" + req.URL.Path))
+	}))
+	defer server.Close()
+
+	source := &FakeClient{
+		ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+			return &lambda.ListLayerVersionsOutput{
+				LayerVersions: []types.LayerVersionsListItem{
+					{Version: 2, LayerVersionArn: awsSDK.String("arn:aws:lambda:us-east-1:012345678912:layer:foo:2")},
+					{Version: 1, LayerVersionArn: awsSDK.String("arn:aws:lambda:us-east-1:012345678912:layer:foo:1")},
+				},
+			}, nil
+		},
+		GetLayerVersionByArnFn: func(ctx context.Context, params *lambda.GetLayerVersionByArnInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionByArnOutput, error) {
+			arn := awsSDK.ToString(params.Arn)
+			version := arn[strings.LastIndex(arn, ":")+1:]
+
+			return &lambda.GetLayerVersionByArnOutput{
+				Version:            map[string]int64{"1": 1, "2": 2}[version],
+				LayerArn:           awsSDK.String("arn:aws:lambda:us-east-1:012345678912:layer:foo"),
+				LayerVersionArn:    params.Arn,
+				Description:        awsSDK.String("version " + version),
+				CompatibleRuntimes: []types.Runtime{types.RuntimePython312},
+				Content: &types.LayerVersionContentOutput{
+					Location:   awsSDK.String(server.URL + "/" + version),
+					CodeSha256: awsSDK.String(codeSha256("package/" + version)),
+				},
+			}, nil
+		},
+		GetLayerVersionPolicyFn: func(ctx context.Context, params *lambda.GetLayerVersionPolicyInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionPolicyOutput, error) {
+			return &lambda.GetLayerVersionPolicyOutput{
+				Policy: awsSDK.String(`{"Statement":[{"Sid":"Share","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::111111111111:root"},"Action":"lambda:GetLayerVersion"}]}`),
+			}, nil
+		},
+	}
+
+	export := func(t *testing.T, out string) {
+		cfg := config.NewConfig(config.WithPermissions(config.Permissions{Mirror: true}))
+		balancer := &layers.Balancer{Config: cfg, ReadClient: source}
+
+		w, err := layers.NewArchiveWriter(out)
+		if err != nil {
+			t.Fatalf("expected to succeed: %v", err)
+		}
+
+		n, err := balancer.Export(context.TODO(), "foo", w)
+		if err != nil || n != 2 {
+			t.Fatalf("expected 2 versions to be exported, got: %d, %v", n, err)
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatalf("expected to succeed: %v", err)
+		}
+	}
+
+	importArchive := func(t *testing.T, in string, cfg *config.Config) ([]string, []string, []layers.RegionResult, error) {
+		archive, err := layers.OpenArchive(in)
+		if err != nil {
+			t.Fatalf("expected to open the archive: %v", err)
+		}
+		defer archive.Close()
+
+		var published, shared []string
+		dest := &FakeClient{
+			ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+				return &lambda.ListLayerVersionsOutput{}, nil
+			},
+			PublishLayerVersionFn: func(ctx context.Context, params *lambda.PublishLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishLayerVersionOutput, error) {
+				published = append(published, fmt.Sprintf("%s=%s", *params.Description, params.Content.ZipFile))
+				return &lambda.PublishLayerVersionOutput{Version: int64(len(published))}, nil
+			},
+			AddLayerVersionPermissionFn: func(ctx context.Context, params *lambda.AddLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.AddLayerVersionPermissionOutput, error) {
+				shared = append(shared, *params.Principal)
+				return nil, nil
+			},
+		}
+
+		cfg.DryRun = false
+		readClient := &FakeClient{}
+		balancer := &layers.Balancer{
+			Config:       cfg,
+			ReadClient:   readClient,
+			Destinations: []layers.Destination{{Region: "eu-west-1", Client: dest}},
+		}
+
+		results, err := balancer.Import(context.TODO(), archive, "foo")
+		if balancer.Cache != nil || balancer.ReadClient != readClient {
+			t.Errorf("expected Import to leave the balancer as it is, got: %+v", balancer)
+		}
+
+		return published, shared, results, err
+	}
+
+	t.Run("Export directory", func(t *testing.T) {
+		dir := t.TempDir()
+		export(t, dir)
+
+		data, err := os.ReadFile(filepath.Join(dir, "foo", "2.json"))
+		if err != nil {
+			t.Fatalf("expected a sidecar: %v", err)
+		}
+
+		var sidecar layers.ArchivedVersion
+		json.Unmarshal(data, &sidecar)
+		if sidecar.Version != 2 || sidecar.Description != "version 2" || sidecar.CodeSha256 != codeSha256("package/2") || len(sidecar.Permissions) != 1 {
+			t.Errorf("wrong sidecar: %+v", sidecar)
+		}
+
+		zip, _ := os.ReadFile(filepath.Join(dir, "foo", "2.zip"))
+		if string(zip) != "package/2" {
+			t.Errorf("wrong package: %q", zip)
+		}
+	})
+
+	t.Run("Import tar", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "backup.tar")
+		export(t, out)
+
+		before := downloads
+		published, shared, results, err := importArchive(t, out, config.NewConfig(config.WithPermissions(config.Permissions{Mirror: true})))
+		if err != nil || results[0].Err != nil {
+			t.Fatalf("expected to succeed: %v, %v", err, results)
+		}
+
+		if strings.Join(published, ",") != "version 1=package/1,version 2=package/2" {
+			t.Errorf("expected both versions in order, got: %v", published)
+		}
+
+		if strings.Join(shared, ",") != "111111111111,111111111111" {
+			t.Errorf("expected the recorded permissions, got: %v", shared)
+		}
+
+		if downloads != before {
+			t.Errorf("expected no downloads during import")
+		}
+	})
+
+	t.Run("Import corrupted package", func(t *testing.T) {
+		dir := t.TempDir()
+		export(t, dir)
+		os.WriteFile(filepath.Join(dir, "foo", "1.zip"), []byte("corrupted"), 0o644)
+
+		published, _, results, err := importArchive(t, dir, config.NewConfig())
+		if err != nil {
+			t.Fatalf("expected to open the archive: %v", err)
+		}
+
+		if !errors.Is(results[0].Err, layers.ErrNoLocation) || len(published) != 0 {
+			t.Errorf("expected the corrupted package not to be published, got: %v", published)
+		}
+	})
+
+	t.Run("Import mirror without recorded permissions", func(t *testing.T) {
+		dir := t.TempDir()
+		w, _ := layers.NewArchiveWriter(dir)
+		(&layers.Balancer{Config: config.NewConfig(), ReadClient: source}).Export(context.TODO(), "foo", w)
+
+		_, _, _, err := importArchive(t, dir, config.NewConfig(config.WithPermissions(config.Permissions{Mirror: true})))
+		if !errors.Is(err, layers.ErrNoPermissions) {
+			t.Errorf("expected missing permissions, got: %v", err)
+		}
+	})
+
+	t.Run("Abort partial export", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "keep.txt"), []byte("keep"), 0o644)
+		tarFile := filepath.Join(t.TempDir(), "backup.tar")
+
+		for _, out := range []string{dir, tarFile} {
+			w, err := layers.NewArchiveWriter(out)
+			if err != nil {
+				t.Fatalf("expected to succeed: %v", err)
+			}
+
+			if err := w.WriteFile("foo/1.zip", strings.NewReader("package/1"), 9); err != nil {
+				t.Fatalf("expected to succeed: %v", err)
+			}
+
+			if err := w.Abort(); err != nil {
+				t.Errorf("expected to abort %s: %v", out, err)
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "foo", "1.zip")); !os.IsNotExist(err) {
+			t.Errorf("expected the exported package to be removed")
+		}
+		if _, err := os.Stat(filepath.Join(dir, "keep.txt")); err != nil {
+			t.Errorf("expected other files to be kept: %v", err)
+		}
+		if _, err := os.Stat(tarFile); !os.IsNotExist(err) {
+			t.Errorf("expected the partial tar file to be removed")
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package layers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidArn = errors.New("invalid layer ARN")
+
+var (
+	partitionPattern = regexp.MustCompile(`^aws(-[a-z]+)*$`)
+	regionPattern    = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+	layerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,140}$`)
+)
+
+// LayerArn identifies a layer, or one of its versions when Version is set.
+type LayerArn struct {
+	Partition string
+	Region    string
+	Account   string
+	Name      string
+	Version   int64
+}
+
+// ParseLayerArn parses arn:partition:lambda:region:account:layer:name with an
+// optional :version suffix.
+func ParseLayerArn(arn string) (LayerArn, error) {
+	fail := func(format string, args ...any) (LayerArn, error) {
+		return LayerArn{}, fmt.Errorf("%w %q: %s", ErrInvalidArn, arn, fmt.Sprintf(format, args...))
+	}
+
+	parts := strings.Split(arn, ":")
+	if len(parts) < 7 || len(parts) > 8 || parts[0] != "arn" {
+		return fail("expected arn:partition:lambda:region:account:layer:name[:version]")
+	}
+
+	a := LayerArn{
+		Partition: parts[1],
+		Region:    parts[3],
+		Account:   parts[4],
+		Name:      parts[6],
+	}
+
+	switch {
+	case !partitionPattern.MatchString(a.Partition):
+		return fail("unknown partition %q", a.Partition)
+	case parts[2] != "lambda":
+		return fail("service is %q, expected lambda", parts[2])
+	case !regionPattern.MatchString(a.Region):
+		return fail("%q is not a region", a.Region)
+	case !accountPattern.MatchString(a.Account):
+		return fail("%q is not a 12 digit account ID", a.Account)
+	case parts[5] != "layer":
+		return fail("resource is %q, expected layer", parts[5])
+	case !layerNamePattern.MatchString(a.Name):
+		return fail("%q is not a layer name", a.Name)
+	}
+
+	if len(parts) == 8 {
+		version, err := strconv.ParseInt(parts[7], 10, 64)
+		if err != nil || version < 1 {
+			return fail("version %q is not a positive number", parts[7])
+		}
+		a.Version = version
+	}
+
+	return a, nil
+}
+
+// String returns the ARN, including the version when it is set.
+func (a LayerArn) String() string {
+	if a.Version > 0 {
+		return fmt.Sprintf("%s:%d", a.Unversioned(), a.Version)
+	}
+
+	return a.Unversioned()
+}
+
+// Unversioned returns the ARN of the layer itself.
+func (a LayerArn) Unversioned() string {
+	return fmt.Sprintf("arn:%s:lambda:%s:%s:layer:%s", a.Partition, a.Region, a.Account, a.Name)
+}
+
+// layerArn strips the version from a layer version ARN.
+func layerArn(layerVersionArn string) string {
+	if i := strings.LastIndex(layerVersionArn, ":"); i >= 0 {
+		return layerVersionArn[:i]
+	}
+
+	return layerVersionArn
+}
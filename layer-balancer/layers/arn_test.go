@@ -0,0 +1,178 @@
+package layers_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestParseLayerArn(t *testing.T) {
+	t.Run("ParseLayerArn valid", func(t *testing.T) {
+		tests := map[string]layers.LayerArn{
+			"arn:aws:lambda:eu-west-1:017000801446:layer:AWSLambdaPowertoolsPythonV3-python312-x86_64":   {Partition: "aws", Region: "eu-west-1", Account: "017000801446", Name: "AWSLambdaPowertoolsPythonV3-python312-x86_64"},
+			"arn:aws:lambda:eu-west-1:017000801446:layer:AWSLambdaPowertoolsPythonV3-python312-x86_64:7": {Partition: "aws", Region: "eu-west-1", Account: "017000801446", Name: "AWSLambdaPowertoolsPythonV3-python312-x86_64", Version: 7},
+			"arn:aws-us-gov:lambda:us-gov-west-1:123456789012:layer:foo_bar:12":                          {Partition: "aws-us-gov", Region: "us-gov-west-1", Account: "123456789012", Name: "foo_bar", Version: 12},
+			"arn:aws-cn:lambda:cn-north-1:123456789012:layer:foo":                                        {Partition: "aws-cn", Region: "cn-north-1", Account: "123456789012", Name: "foo"},
+		}
+
+		for arn, expected := range tests {
+			a, err := layers.ParseLayerArn(arn)
+			if err != nil {
+				t.Errorf("expected %s to parse: %v", arn, err)
+				continue
+			}
+
+			if a != expected {
+				t.Errorf("wrong result for %s: %+v", arn, a)
+			}
+
+			if a.String() != arn {
+				t.Errorf("expected %s to round trip, got: %s", arn, a)
+			}
+		}
+	})
+
+	t.Run("ParseLayerArn invalid", func(t *testing.T) {
+		for _, arn := range []string{
+			"AWSLambdaPowertoolsPythonV3-python312-x86_64",
+			"arn:aws:lambda:eu-west-1:017000801446:function:foo",
+			"arn:aws:s3:eu-west-1:017000801446:layer:foo",
+			"arn:aws:lambda:europe:017000801446:layer:foo",
+			"arn:aws:lambda:eu-west-1:0170008014:layer:foo",
+			"arn:aws:lambda:eu-west-1:017000801446:layer:foo:latest",
+			"arn:aws:lambda:eu-west-1:017000801446:layer:foo:0",
+			"arn:aws:lambda:eu-west-1:017000801446:layer:foo.bar",
+			"arn:gcp:lambda:eu-west-1:017000801446:layer:foo",
+		} {
+			if _, err := layers.ParseLayerArn(arn); !errors.Is(err, layers.ErrInvalidArn) {
+				t.Errorf("expected %s to be invalid, got: %v", arn, err)
+			}
+		}
+	})
+}
+
+func TestBalanceArn(t *testing.T) {
+	var listed, fetched []string
+	source := &FakeClient{
+		ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+			listed = append(listed, *params.LayerName)
+			return &lambda.ListLayerVersionsOutput{
+				LayerVersions: []types.LayerVersionsListItem{
+					{Version: 1, LayerVersionArn: awsSDK.String("arn:aws:lambda:us-east-1:111111111111:layer:shared:1")},
+				},
+			}, nil
+		},
+		GetLayerVersionByArnFn: func(ctx context.Context, params *lambda.GetLayerVersionByArnInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionByArnOutput, error) {
+			fetched = append(fetched, *params.Arn)
+			return &lambda.GetLayerVersionByArnOutput{
+				Version:         1,
+				LayerArn:        awsSDK.String("arn:aws:lambda:us-east-1:111111111111:layer:shared"),
+				LayerVersionArn: params.Arn,
+				Content:         &types.LayerVersionContentOutput{},
+			}, nil
+		},
+	}
+
+	var destNames []string
+	dest := &FakeClient{
+		ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+			destNames = append(destNames, *params.LayerName)
+			return &lambda.ListLayerVersionsOutput{}, nil
+		},
+	}
+
+	balancer := &layers.Balancer{
+		Config:       config.NewConfig(),
+		ReadClient:   source,
+		ReadAccount:  "111111111111",
+		Destinations: []layers.Destination{{Region: "eu-west-1", Client: dest}},
+	}
+
+	t.Run("BalanceArn version", func(t *testing.T) {
+		listed, fetched, destNames = nil, nil, nil
+		arn, _ := layers.ParseLayerArn("arn:aws:lambda:us-east-1:111111111111:layer:shared:1")
+
+		results, err := balancer.BalanceArn(context.TODO(), arn, "mine")
+		if err != nil || results[0].Err != nil {
+			t.Fatalf("expected to succeed: %v, %v", err, results)
+		}
+
+		if len(listed) != 0 || len(fetched) != 1 || fetched[0] != arn.String() {
+			t.Errorf("expected only the version to be fetched, listed %v fetched %v", listed, fetched)
+		}
+
+		if len(destNames) != 1 || destNames[0] != "mine" || results[0].Copied != 1 {
+			t.Errorf("expected a copy to the destination name, got: %v, %+v", destNames, results[0])
+		}
+	})
+
+	t.Run("BalanceArn layer", func(t *testing.T) {
+		listed, fetched, destNames = nil, nil, nil
+		arn, _ := layers.ParseLayerArn("arn:aws:lambda:us-east-1:111111111111:layer:shared")
+
+		if _, err := balancer.BalanceArn(context.TODO(), arn, "shared"); err != nil {
+			t.Fatalf("expected to succeed: %v", err)
+		}
+
+		if len(listed) != 1 || listed[0] != arn.String() {
+			t.Errorf("expected the layer to be listed by ARN, got: %v", listed)
+		}
+	})
+
+	t.Run("BalanceArn mapped name", func(t *testing.T) {
+		listed, fetched, destNames = nil, nil, nil
+		arn, _ := layers.ParseLayerArn("arn:aws:lambda:us-east-1:111111111111:layer:shared:1")
+
+		mapped := &layers.Balancer{
+			Config: config.NewConfig(config.WithNameMapping(config.NameMapping{
+				Pattern:  "(.+)",
+				Template: "corp-$1",
+			})),
+			ReadClient:   source,
+			Destinations: []layers.Destination{{Region: "eu-west-1", Client: dest}},
+		}
+
+		if _, err := mapped.BalanceArn(context.TODO(), arn, ""); err != nil {
+			t.Fatalf("expected to succeed: %v", err)
+		}
+
+		if len(destNames) != 1 || destNames[0] != "corp-shared" {
+			t.Errorf("expected the destination to be discovered by its mapped name, got: %v", destNames)
+		}
+	})
+
+	t.Run("BalanceArn shared layer", func(t *testing.T) {
+		listed, fetched, destNames = nil, nil, nil
+		shared := &layers.Balancer{
+			Config:       config.NewConfig(),
+			ReadClient:   source,
+			ReadAccount:  "012345678912",
+			Destinations: []layers.Destination{{Region: "eu-west-1", Client: dest}},
+		}
+
+		arn, _ := layers.ParseLayerArn("arn:aws:lambda:us-east-1:111111111111:layer:shared")
+		if _, err := shared.BalanceArn(context.TODO(), arn, "shared"); !errors.Is(err, layers.ErrCrossAccount) {
+			t.Errorf("expected an unversioned ARN of another account to fail, got: %v", err)
+		}
+
+		if len(listed) != 0 {
+			t.Errorf("expected nothing to be listed, got: %v", listed)
+		}
+
+		arn, _ = layers.ParseLayerArn("arn:aws:lambda:us-east-1:111111111111:layer:shared:1")
+		if _, err := shared.BalanceArn(context.TODO(), arn, "shared"); err != nil {
+			t.Errorf("expected a versioned ARN of another account to succeed: %v", err)
+		}
+
+		shared.Config.Permissions.Mirror = true
+		if _, err := shared.BalanceArn(context.TODO(), arn, "shared"); !errors.Is(err, layers.ErrCrossAccount) {
+			t.Errorf("expected mirroring the policy of another account to fail, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package layers
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+type PermissionAudit struct {
+	LayerName string       `json:"layerName"`
+	Region    string       `json:"region"`
+	Version   int64        `json:"version"`
+	Missing   []Permission `json:"missing,omitempty"`
+	Extra     []Permission `json:"extra,omitempty"`
+	Repaired  bool         `json:"repaired,omitempty"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// Ok reports whether the version needs no further changes, extra statements
+// only count when they were meant to be removed.
+func (a PermissionAudit) Ok(removeExtra bool) bool {
+	if a.Error != "" {
+		return false
+	}
+
+	if a.Repaired {
+		return true
+	}
+
+	return len(a.Missing) == 0 && (!removeExtra || len(a.Extra) == 0)
+}
+
+type AuditOptions struct {
+	Repair      bool
+	RemoveExtra bool
+}
+
+// AuditPermissions compares the policy of every version of the copy of layerName
+// in dest with the permissions the balancer would have added. With Repair set
+// missing statements are added, and extra statements removed when RemoveExtra is
+// set as well.
+func (b *Balancer) AuditPermissions(ctx context.Context, dest Destination, layerName string, opts AuditOptions) ([]PermissionAudit, error) {
+	destName, err := b.DestinationName(layerName)
+	if err != nil {
+		return nil, err
+	}
+
+	listVersions, err := DiscoverVersions(ctx, dest.Client, destName)
+	if err != nil {
+		return nil, err
+	}
+
+	// mirrored policies come from the source version holding the same
+	// package, version numbers can differ between the regions
+	sources := map[int64]*lambda.GetLayerVersionByArnOutput{}
+	if b.Config.Permissions.Mirror && !b.Config.Permissions.Explicit() {
+		sourceList, err := DiscoverVersions(ctx, b.ReadClient, layerName)
+		if err != nil {
+			return nil, err
+		}
+
+		sourceVersions, err := EnrichVersionsConcurrently(ctx, b.ReadClient, sourceList, b.Config.Parallelism)
+		if err != nil {
+			return nil, err
+		}
+
+		destVersions, err := EnrichVersionsConcurrently(ctx, dest.Client, listVersions, b.Config.Parallelism)
+		if err != nil {
+			return nil, err
+		}
+
+		matched, _ := MatchVersions(sourceVersions, destVersions)
+		for _, v := range sourceVersions {
+			if d, ok := matched[v.Version]; ok {
+				sources[d.Version] = v
+			}
+		}
+	}
+
+	var audits []PermissionAudit
+	for _, v := range listVersions {
+		audit := PermissionAudit{
+			LayerName: destName,
+			Region:    dest.Region,
+			Version:   v.Version,
+		}
+
+		if err := b.auditVersion(ctx, dest, destName, v.Version, sources, opts, &audit); err != nil {
+			audit.Error = err.Error()
+		}
+
+		audits = append(audits, audit)
+	}
+
+	return audits, nil
+}
+
+func (b *Balancer) auditVersion(ctx context.Context, dest Destination, layerName string, version int64, sources map[int64]*lambda.GetLayerVersionByArnOutput, opts AuditOptions, audit *PermissionAudit) error {
+	source := sources[version]
+	if source == nil && b.Config.Permissions.Mirror && !b.Config.Permissions.Explicit() {
+		return fmt.Errorf("no source version with the package of version %d to mirror", version)
+	}
+
+	expected, err := b.permissions(ctx, source)
+	if err != nil {
+		return err
+	}
+
+	policy, err := GetPolicy(ctx, dest.Client, layerName, version)
+	if err != nil {
+		return err
+	}
+
+	current, err := policy.Permissions()
+	if err != nil {
+		return err
+	}
+
+	audit.Missing, audit.Extra = comparePermissions(expected, current)
+
+	if !opts.Repair || (len(audit.Missing) == 0 && (!opts.RemoveExtra || len(audit.Extra) == 0)) {
+		return nil
+	}
+
+	log.Printf("Repairing: %s:%d in %s", layerName, version, dest.Region)
+
+	if b.Config.DryRun {
+		return nil
+	}
+
+	if opts.RemoveExtra {
+		for _, p := range audit.Extra {
+			if _, err := dest.Client.RemoveLayerVersionPermission(ctx, &lambda.RemoveLayerVersionPermissionInput{
+				LayerName:     awsSDK.String(layerName),
+				VersionNumber: awsSDK.Int64(version),
+				StatementId:   awsSDK.String(p.StatementId),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := AddPermissions(ctx, dest.Client, layerName, version, audit.Missing); err != nil {
+		return err
+	}
+
+	audit.Repaired = true
+
+	return nil
+}
+
+// comparePermissions matches statements by who they grant access to, statement IDs are ignored.
+func comparePermissions(expected []Permission, current []Permission) ([]Permission, []Permission) {
+	grant := func(p Permission) string {
+		return p.Principal + "|" + p.OrganizationId
+	}
+
+	present := map[string]bool{}
+	for _, p := range current {
+		present[grant(p)] = true
+	}
+
+	wanted := map[string]bool{}
+	var missing []Permission
+	for _, p := range expected {
+		wanted[grant(p)] = true
+		if !present[grant(p)] {
+			missing = append(missing, p)
+		}
+	}
+
+	var extra []Permission
+	for _, p := range current {
+		if !wanted[grant(p)] {
+			extra = append(extra, p)
+		}
+	}
+
+	return missing, extra
+}
@@ -0,0 +1,193 @@
+package layers_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestAuditPermissions(t *testing.T) {
+	policies := map[int64]string{
+		1: `{"Statement":[{"Sid":"PublicLayerAccess","Effect":"Allow","Principal":"*","Action":"lambda:GetLayerVersion"}]}`,
+		3: `{"Statement":[{"Sid":"PublicLayerAccess","Effect":"Allow","Principal":"*","Action":"lambda:GetLayerVersion"},{"Sid":"other","Effect":"Allow","Principal":{"AWS":"223456789012"},"Action":"lambda:GetLayerVersion"}]}`,
+	}
+
+	var added []*lambda.AddLayerVersionPermissionInput
+	var removed []*lambda.RemoveLayerVersionPermissionInput
+	client := &FakeClient{
+		ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+			return &lambda.ListLayerVersionsOutput{
+				LayerVersions: []types.LayerVersionsListItem{{Version: 1}, {Version: 2}, {Version: 3}},
+			}, nil
+		},
+		GetLayerVersionPolicyFn: func(ctx context.Context, params *lambda.GetLayerVersionPolicyInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionPolicyOutput, error) {
+			policy, ok := policies[*params.VersionNumber]
+			if !ok {
+				return nil, &types.ResourceNotFoundException{}
+			}
+
+			return &lambda.GetLayerVersionPolicyOutput{
+				Policy: awsSDK.String(policy),
+			}, nil
+		},
+		AddLayerVersionPermissionFn: func(ctx context.Context, params *lambda.AddLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.AddLayerVersionPermissionOutput, error) {
+			added = append(added, params)
+
+			// another run added the same statement first
+			policies[*params.VersionNumber] = policies[1]
+			return nil, &types.ResourceConflictException{}
+		},
+		RemoveLayerVersionPermissionFn: func(ctx context.Context, params *lambda.RemoveLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.RemoveLayerVersionPermissionOutput, error) {
+			removed = append(removed, params)
+			return nil, nil
+		},
+	}
+
+	dest := layers.Destination{Region: "eu-west-1", Client: client}
+
+	t.Run("AuditPermissions audit", func(t *testing.T) {
+		balancer := &layers.Balancer{Config: config.NewConfig()}
+
+		audits, err := balancer.AuditPermissions(context.TODO(), dest, "foo", layers.AuditOptions{})
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		if !audits[0].Ok(true) {
+			t.Errorf("expected version 1 to be ok: %+v", audits[0])
+		}
+
+		if audits[1].Ok(false) || len(audits[1].Missing) != 1 {
+			t.Errorf("expected version 2 to miss the public statement: %+v", audits[1])
+		}
+
+		if !audits[2].Ok(false) || audits[2].Ok(true) || len(audits[2].Extra) != 1 {
+			t.Errorf("expected version 3 to have an extra statement: %+v", audits[2])
+		}
+
+		if len(added) != 0 || len(removed) != 0 {
+			t.Errorf("expected audit not to change anything")
+		}
+	})
+
+	t.Run("AuditPermissions repair", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.DryRun = false
+		balancer := &layers.Balancer{Config: cfg}
+
+		audits, err := balancer.AuditPermissions(context.TODO(), dest, "foo", layers.AuditOptions{Repair: true, RemoveExtra: true})
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		for _, a := range audits {
+			if !a.Ok(true) {
+				t.Errorf("expected version %d to be repaired: %+v", a.Version, a)
+			}
+		}
+
+		if len(added) != 1 || *added[0].VersionNumber != 2 || *added[0].StatementId != layers.PublicStatementId {
+			t.Errorf("expected the public statement to be added to version 2, got: %d", len(added))
+		}
+
+		if len(removed) != 1 || *removed[0].VersionNumber != 3 || *removed[0].StatementId != "other" {
+			t.Errorf("expected the extra statement to be removed from version 3, got: %d", len(removed))
+		}
+	})
+
+	t.Run("AuditPermissions mirror by content", func(t *testing.T) {
+		public := `{"Statement":[{"Sid":"PublicLayerAccess","Effect":"Allow","Principal":"*","Action":"lambda:GetLayerVersion"}]}`
+		private := `{"Statement":[{"Sid":"AccountAccess223456789012","Effect":"Allow","Principal":{"AWS":"223456789012"},"Action":"lambda:GetLayerVersion"}]}`
+
+		// source version 1 was never copied, so the numbers of the copies are off by one
+		newClient := func(region string, shas map[int64]string, policies map[int64]string) *FakeClient {
+			return &FakeClient{
+				ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+					var versions []types.LayerVersionsListItem
+					for v := int64(1); v <= int64(len(shas)); v++ {
+						versions = append(versions, types.LayerVersionsListItem{
+							Version:         v,
+							LayerVersionArn: awsSDK.String(fmt.Sprintf("arn:aws:lambda:%s:012345678912:layer:foo:%d", region, v)),
+						})
+					}
+					return &lambda.ListLayerVersionsOutput{LayerVersions: versions}, nil
+				},
+				GetLayerVersionByArnFn: func(ctx context.Context, params *lambda.GetLayerVersionByArnInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionByArnOutput, error) {
+					arn := awsSDK.ToString(params.Arn)
+					version, _ := strconv.ParseInt(arn[strings.LastIndex(arn, ":")+1:], 10, 64)
+					return &lambda.GetLayerVersionByArnOutput{
+						Version:         version,
+						LayerArn:        awsSDK.String(arn[:strings.LastIndex(arn, ":")]),
+						LayerVersionArn: params.Arn,
+						Content:         &types.LayerVersionContentOutput{CodeSha256: awsSDK.String(shas[version])},
+					}, nil
+				},
+				GetLayerVersionPolicyFn: func(ctx context.Context, params *lambda.GetLayerVersionPolicyInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionPolicyOutput, error) {
+					return &lambda.GetLayerVersionPolicyOutput{Policy: awsSDK.String(policies[*params.VersionNumber])}, nil
+				},
+			}
+		}
+
+		cfg := config.NewConfig()
+		cfg.Permissions.Mirror = true
+		balancer := &layers.Balancer{
+			Config:     cfg,
+			ReadClient: newClient("us-east-1", map[int64]string{1: "a", 2: "b", 3: "c"}, map[int64]string{1: public, 2: private, 3: public}),
+		}
+		dest := layers.Destination{
+			Region: "eu-west-1",
+			Client: newClient("eu-west-1", map[int64]string{1: "b", 2: "c"}, map[int64]string{1: private, 2: public}),
+		}
+
+		audits, err := balancer.AuditPermissions(context.TODO(), dest, "foo", layers.AuditOptions{})
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		for _, a := range audits {
+			if !a.Ok(true) {
+				t.Errorf("expected version %d to match the source with the same package: %+v", a.Version, a)
+			}
+		}
+	})
+
+	t.Run("AuditPermissions repair conflicting statement", func(t *testing.T) {
+		// the public statement ID is taken by a grant to a single account
+		conflicting := &FakeClient{
+			ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+				return &lambda.ListLayerVersionsOutput{
+					LayerVersions: []types.LayerVersionsListItem{{Version: 1}},
+				}, nil
+			},
+			GetLayerVersionPolicyFn: func(ctx context.Context, params *lambda.GetLayerVersionPolicyInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionPolicyOutput, error) {
+				return &lambda.GetLayerVersionPolicyOutput{
+					Policy: awsSDK.String(`{"Statement":[{"Sid":"PublicLayerAccess","Effect":"Allow","Principal":{"AWS":"223456789012"},"Action":"lambda:GetLayerVersion"}]}`),
+				}, nil
+			},
+			AddLayerVersionPermissionFn: func(ctx context.Context, params *lambda.AddLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.AddLayerVersionPermissionOutput, error) {
+				return nil, &types.ResourceConflictException{}
+			},
+		}
+
+		cfg := config.NewConfig()
+		cfg.DryRun = false
+		balancer := &layers.Balancer{Config: cfg}
+
+		audits, err := balancer.AuditPermissions(context.TODO(), layers.Destination{Region: "eu-west-1", Client: conflicting}, "foo", layers.AuditOptions{Repair: true})
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		if audits[0].Repaired || audits[0].Ok(false) || !strings.Contains(audits[0].Error, layers.ErrPermissionConflict.Error()) {
+			t.Errorf("expected the conflicting statement to be reported, got: %+v", audits[0])
+		}
+	})
+}
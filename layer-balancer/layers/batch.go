@@ -0,0 +1,121 @@
+package layers
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+type LayerResult struct {
+	LayerName string
+	Regions   []RegionResult
+	Err       error
+}
+
+func (r LayerResult) Failed() bool {
+	if r.Err != nil {
+		return true
+	}
+
+	for _, region := range r.Regions {
+		if region.Err != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BalanceAll balances every layer in turn, sharing the balancer clients and
+// package cache. A failing layer is recorded and the next one is attempted.
+func (b *Balancer) BalanceAll(ctx context.Context, layerNames []string) []LayerResult {
+	var results []LayerResult
+	for _, name := range layerNames {
+		regions, err := b.Balance(ctx, name)
+		results = append(results, LayerResult{
+			LayerName: name,
+			Regions:   regions,
+			Err:       err,
+		})
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return results
+}
+
+// ResolveLayers returns the explicitly named layers plus every layer in the
+// client region matching prefix or the path.Match style pattern, sorted and
+// without duplicates. ListLayers is only called when a prefix or pattern is set.
+func ResolveLayers(ctx context.Context, client LambdaClient, names []string, prefix string, pattern string) ([]string, error) {
+	if pattern != "" {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, name := range names {
+		if name != "" {
+			seen[name] = true
+		}
+	}
+
+	if prefix != "" || pattern != "" {
+		available, err := ListLayerNames(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range available {
+			if prefix != "" && strings.HasPrefix(name, prefix) {
+				seen[name] = true
+			}
+
+			if pattern != "" {
+				if ok, _ := path.Match(pattern, name); ok {
+					seen[name] = true
+				}
+			}
+		}
+	}
+
+	var resolved []string
+	for name := range seen {
+		resolved = append(resolved, name)
+	}
+	sort.Strings(resolved)
+
+	return resolved, nil
+}
+
+func ListLayerNames(ctx context.Context, client LambdaClient) ([]string, error) {
+	var names []string
+	var marker *string
+	for {
+		out, err := client.ListLayers(ctx, &lambda.ListLayersInput{
+			MaxItems: awsSDK.Int32(50),
+			Marker:   marker,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, l := range out.Layers {
+			names = append(names, awsSDK.ToString(l.LayerName))
+		}
+
+		if out.NextMarker == nil {
+			break
+		}
+		marker = out.NextMarker
+	}
+
+	return names, nil
+}
@@ -0,0 +1,271 @@
+package layers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMemoryCacheSize is enough to hold a few versions of the larger
+// Powertools layers while they are copied to every write region.
+const DefaultMemoryCacheSize = 512 << 20
+
+type PackageCache interface {
+	Get(key string) ([]byte, bool)
+	Put(key string, zip []byte)
+}
+
+// MemoryCache keeps downloaded packages in memory, evicting the oldest
+// entries once the total size goes over maxSize.
+type MemoryCache struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	order   []string
+	entries map[string][]byte
+}
+
+func NewMemoryCache(maxSize int64) *MemoryCache {
+	return &MemoryCache{
+		maxSize: maxSize,
+		entries: map[string][]byte{},
+	}
+}
+
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	zip, ok := c.entries[key]
+	return zip, ok
+}
+
+func (c *MemoryCache) Put(key string, zip []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok || int64(len(zip)) > c.maxSize {
+		return
+	}
+
+	for c.size+int64(len(zip)) > c.maxSize && len(c.order) > 0 {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		c.size -= int64(len(c.entries[oldest]))
+		delete(c.entries, oldest)
+	}
+
+	c.entries[key] = zip
+	c.order = append(c.order, key)
+	c.size += int64(len(zip))
+}
+
+// DefaultDiskCacheSize bounds the cache directory when no size is configured.
+const DefaultDiskCacheSize = 2 << 30
+
+// DiskCache keeps packages in a directory, one file per CodeSha256, so it can
+// be shared between runs. Entries are verified against their key when read
+// and the least recently used ones are removed once the directory grows over
+// maxSize.
+type DiskCache struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+}
+
+func NewDiskCache(dir string, maxSize int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	if maxSize <= 0 {
+		maxSize = DefaultDiskCacheSize
+	}
+
+	return &DiskCache{
+		dir:     dir,
+		maxSize: maxSize,
+	}, nil
+}
+
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	path, ok := c.path(key)
+	if !ok {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	zip, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	sum := sha256.Sum256(zip)
+	if base64.StdEncoding.EncodeToString(sum[:]) != key {
+		log.Printf("Removing corrupted cache entry: %s", path)
+		os.Remove(path)
+		return nil, false
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return zip, true
+}
+
+func (c *DiskCache) Put(key string, zip []byte) {
+	path, ok := c.path(key)
+	if !ok || int64(len(zip)) > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		return
+	}
+
+	// write next to the entry and rename so a concurrent run never reads a partial file
+	f, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		log.Printf("Unable to cache package: %v", err)
+		return
+	}
+
+	_, err = f.Write(zip)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		log.Printf("Unable to cache package: %v", err)
+		return
+	}
+
+	c.evict()
+}
+
+// Open returns the package stored under key, it isn't verified here so the
+// reader has to, as fetchPackage does while copying it.
+func (c *DiskCache) Open(key string) (io.ReadCloser, bool) {
+	path, ok := c.path(key)
+	if !ok {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return f, true
+}
+
+// PutFile copies the package at file into the cache. An existing entry is
+// replaced, so one that failed verification is repaired by the next download.
+func (c *DiskCache) PutFile(key string, file string) {
+	path, ok := c.path(key)
+	if !ok {
+		return
+	}
+
+	src, err := os.Open(file)
+	if err != nil {
+		log.Printf("Unable to cache package: %v", err)
+		return
+	}
+	defer src.Close()
+
+	if info, err := src.Stat(); err != nil || info.Size() > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		log.Printf("Unable to cache package: %v", err)
+		return
+	}
+
+	_, err = io.Copy(f, src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		log.Printf("Unable to cache package: %v", err)
+		return
+	}
+
+	c.evict()
+}
+
+func (c *DiskCache) evict() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	var files []fs.FileInfo
+	var size int64
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		files = append(files, info)
+		size += info.Size()
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	for _, f := range files {
+		if size <= c.maxSize {
+			break
+		}
+
+		if err := os.Remove(filepath.Join(c.dir, f.Name())); err == nil {
+			size -= f.Size()
+		}
+	}
+}
+
+// path maps a base64 CodeSha256 to a file name, keys that aren't a SHA-256
+// aren't cached.
+func (c *DiskCache) path(key string) (string, bool) {
+	sum, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(sum) != sha256.Size {
+		return "", false
+	}
+
+	return filepath.Join(c.dir, hex.EncodeToString(sum)+".zip"), true
+}
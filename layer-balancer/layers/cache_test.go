@@ -0,0 +1,152 @@
+package layers_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+)
+
+func TestMemoryCache(t *testing.T) {
+	t.Run("MemoryCache hit", func(t *testing.T) {
+		cache := layers.NewMemoryCache(10)
+		cache.Put("a", []byte("OK"))
+
+		zip, ok := cache.Get("a")
+		if !ok || string(zip) != "OK" {
+			t.Errorf("expected cache hit")
+		}
+	})
+
+	t.Run("MemoryCache eviction", func(t *testing.T) {
+		cache := layers.NewMemoryCache(4)
+		cache.Put("a", []byte("aa"))
+		cache.Put("b", []byte("bb"))
+		cache.Put("c", []byte("cc"))
+
+		if _, ok := cache.Get("a"); ok {
+			t.Errorf("expected oldest entry to be evicted")
+		}
+
+		if _, ok := cache.Get("c"); !ok {
+			t.Errorf("expected newest entry to be cached")
+		}
+	})
+
+	t.Run("MemoryCache too large", func(t *testing.T) {
+		cache := layers.NewMemoryCache(1)
+		cache.Put("a", []byte("aa"))
+
+		if _, ok := cache.Get("a"); ok {
+			t.Errorf("expected entry larger than the cache to be skipped")
+		}
+	})
+}
+
+func codeSha256(zip string) string {
+	sum := sha256.Sum256([]byte(zip))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestDiskCache(t *testing.T) {
+	t.Run("DiskCache hit across instances", func(t *testing.T) {
+		dir := t.TempDir()
+
+		cache, err := layers.NewDiskCache(dir, 0)
+		if err != nil {
+			t.Fatalf("expected to succeed: %v", err)
+		}
+		cache.Put(okSha256, []byte("OK"))
+
+		reopened, _ := layers.NewDiskCache(dir, 0)
+		zip, ok := reopened.Get(okSha256)
+		if !ok || string(zip) != "OK" {
+			t.Errorf("expected cache hit")
+		}
+	})
+
+	t.Run("DiskCache corrupted entry", func(t *testing.T) {
+		dir := t.TempDir()
+		cache, _ := layers.NewDiskCache(dir, 0)
+		cache.Put(okSha256, []byte("OK"))
+
+		files, _ := filepath.Glob(filepath.Join(dir, "*.zip"))
+		if len(files) != 1 {
+			t.Fatalf("expected a single entry, got: %v", files)
+		}
+		os.WriteFile(files[0], []byte("KO"), 0o644)
+
+		if _, ok := cache.Get(okSha256); ok {
+			t.Errorf("expected corrupted entry to miss")
+		}
+
+		if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+			t.Errorf("expected corrupted entry to be removed")
+		}
+	})
+
+	t.Run("DiskCache PutFile replaces entry", func(t *testing.T) {
+		dir := t.TempDir()
+		cache, _ := layers.NewDiskCache(dir, 0)
+		cache.Put(okSha256, []byte("KO"))
+
+		file := filepath.Join(t.TempDir(), "layer.zip")
+		os.WriteFile(file, []byte("OK"), 0o644)
+		cache.PutFile(okSha256, file)
+
+		r, ok := cache.Open(okSha256)
+		if !ok {
+			t.Fatalf("expected cache hit")
+		}
+		defer r.Close()
+
+		zip, _ := io.ReadAll(r)
+		if string(zip) != "OK" {
+			t.Errorf("expected the entry to be replaced, got: %q", zip)
+		}
+	})
+
+	t.Run("DiskCache eviction", func(t *testing.T) {
+		dir := t.TempDir()
+		cache, _ := layers.NewDiskCache(dir, 4)
+
+		cache.Put(codeSha256("aa"), []byte("aa"))
+		cache.Put(codeSha256("bb"), []byte("bb"))
+
+		// make aa the most recently used entry
+		past := time.Now().Add(-time.Hour)
+		files, _ := filepath.Glob(filepath.Join(dir, "*.zip"))
+		for _, f := range files {
+			os.Chtimes(f, past, past)
+		}
+		cache.Get(codeSha256("aa"))
+
+		cache.Put(codeSha256("cc"), []byte("cc"))
+
+		if _, ok := cache.Get(codeSha256("bb")); ok {
+			t.Errorf("expected least recently used entry to be evicted")
+		}
+
+		if _, ok := cache.Get(codeSha256("aa")); !ok {
+			t.Errorf("expected recently used entry to be kept")
+		}
+
+		if _, ok := cache.Get(codeSha256("cc")); !ok {
+			t.Errorf("expected newest entry to be cached")
+		}
+	})
+
+	t.Run("DiskCache invalid key", func(t *testing.T) {
+		cache, _ := layers.NewDiskCache(t.TempDir(), 0)
+		cache.Put("sha", []byte("OK"))
+
+		if _, ok := cache.Get("sha"); ok {
+			t.Errorf("expected keys that aren't a SHA-256 to be skipped")
+		}
+	})
+}
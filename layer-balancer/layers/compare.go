@@ -0,0 +1,62 @@
+package layers
+
+import (
+	"slices"
+	"strings"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// SameContent reports whether two versions have the same package and metadata,
+// runtimes and architectures are compared regardless of order.
+func SameContent(a *lambda.GetLayerVersionByArnOutput, b *lambda.GetLayerVersionByArnOutput) bool {
+	return contentKey(a) == contentKey(b)
+}
+
+// MatchVersions pairs every source version with a destination version holding
+// the same content. Each destination version is used at most once, source
+// versions without a match are returned in order as missing.
+func MatchVersions(source []*lambda.GetLayerVersionByArnOutput, dest []*lambda.GetLayerVersionByArnOutput) (map[int64]*lambda.GetLayerVersionByArnOutput, []*lambda.GetLayerVersionByArnOutput) {
+	available := map[string][]*lambda.GetLayerVersionByArnOutput{}
+	for _, d := range dest {
+		key := contentKey(d)
+		available[key] = append(available[key], d)
+	}
+
+	matched := map[int64]*lambda.GetLayerVersionByArnOutput{}
+	var missing []*lambda.GetLayerVersionByArnOutput
+	for _, s := range source {
+		key := contentKey(s)
+		if candidates := available[key]; len(candidates) > 0 {
+			matched[s.Version] = candidates[0]
+			available[key] = candidates[1:]
+			continue
+		}
+
+		missing = append(missing, s)
+	}
+
+	return matched, missing
+}
+
+func contentKey(v *lambda.GetLayerVersionByArnOutput) string {
+	var runtimes []string
+	for _, r := range v.CompatibleRuntimes {
+		runtimes = append(runtimes, string(r))
+	}
+	slices.Sort(runtimes)
+
+	var architectures []string
+	for _, a := range v.CompatibleArchitectures {
+		architectures = append(architectures, string(a))
+	}
+	slices.Sort(architectures)
+
+	return strings.Join([]string{
+		codeSha256(v),
+		awsSDK.ToString(v.Description),
+		strings.Join(runtimes, ","),
+		strings.Join(architectures, ","),
+	}, "\x00")
+}
@@ -0,0 +1,62 @@
+package layers_test
+
+import (
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestMatchVersions(t *testing.T) {
+	newVersion := func(version int64, sha string, runtimes ...types.Runtime) *lambda.GetLayerVersionByArnOutput {
+		return &lambda.GetLayerVersionByArnOutput{
+			Version:            version,
+			Description:        awsSDK.String("hello"),
+			CompatibleRuntimes: runtimes,
+			Content: &types.LayerVersionContentOutput{
+				CodeSha256: awsSDK.String(sha),
+			},
+		}
+	}
+
+	t.Run("SameContent runtime order", func(t *testing.T) {
+		a := newVersion(1, "sha", types.RuntimePython312, types.RuntimePython313)
+		b := newVersion(7, "sha", types.RuntimePython313, types.RuntimePython312)
+
+		if !layers.SameContent(a, b) {
+			t.Errorf("expected versions to match")
+		}
+	})
+
+	t.Run("SameContent description", func(t *testing.T) {
+		a := newVersion(1, "sha")
+		b := newVersion(1, "sha")
+		b.Description = awsSDK.String("other")
+
+		if layers.SameContent(a, b) {
+			t.Errorf("expected versions not to match")
+		}
+	})
+
+	t.Run("MatchVersions duplicates", func(t *testing.T) {
+		source := []*lambda.GetLayerVersionByArnOutput{
+			newVersion(1, "a"),
+			newVersion(2, "a"),
+			newVersion(3, "b"),
+		}
+		dest := []*lambda.GetLayerVersionByArnOutput{
+			newVersion(1, "a"),
+		}
+
+		matched, missing := layers.MatchVersions(source, dest)
+		if len(matched) != 1 || matched[1] == nil {
+			t.Errorf("expected version 1 to match, got: %v", matched)
+		}
+
+		if len(missing) != 2 || missing[0].Version != 2 || missing[1].Version != 3 {
+			t.Errorf("expected versions 2 and 3 to be missing, got: %v", missing)
+		}
+	})
+}
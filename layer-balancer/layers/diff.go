@@ -0,0 +1,199 @@
+package layers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+const (
+	DriftMissing       = "missing"
+	DriftExtra         = "extra"
+	DriftCodeSha256    = "code-sha256"
+	DriftRuntimes      = "runtimes"
+	DriftArchitectures = "architectures"
+	DriftLicense       = "license"
+	DriftDescription   = "description"
+	DriftNotPublic     = "not-public"
+)
+
+type LayerDiff struct {
+	LayerName       string        `json:"layerName"`
+	DestinationName string        `json:"destinationName"`
+	ReadRegion      string        `json:"readRegion"`
+	WriteRegion     string        `json:"writeRegion"`
+	Versions        []VersionDiff `json:"versions"`
+}
+
+type VersionDiff struct {
+	Version        int64    `json:"version"`
+	SourceArn      string   `json:"sourceArn,omitempty"`
+	DestinationArn string   `json:"destinationArn,omitempty"`
+	Drift          []string `json:"drift,omitempty"`
+}
+
+func (d *LayerDiff) Drifted() bool {
+	for _, v := range d.Versions {
+		if len(v.Drift) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Diff compares the history of layerName in the read region with the history
+// of destName in the write region version by version, it only performs read
+// operations. A source layer without versions is an error, as there would be
+// nothing to compare, a destination without versions has every version missing.
+func Diff(ctx context.Context, readClient LambdaClient, dest Destination, readRegion string, layerName string, destName string) (*LayerDiff, error) {
+	source, sourcePublic, err := describeVersions(ctx, readClient, layerName)
+	if err != nil {
+		return nil, fmt.Errorf("%s in %s: %w", layerName, readRegion, err)
+	}
+
+	existing, destPublic, err := describeVersions(ctx, dest.Client, destName)
+	if err != nil && !errors.Is(err, ErrNoVersions) {
+		return nil, err
+	}
+
+	return &LayerDiff{
+		LayerName:       layerName,
+		DestinationName: destName,
+		ReadRegion:      readRegion,
+		WriteRegion:     dest.Region,
+		Versions:        CompareVersions(source, existing, sourcePublic, destPublic),
+	}, nil
+}
+
+// CompareVersions pairs versions by number, public holds the versions of each
+// side that can be used by any account.
+func CompareVersions(source []*lambda.GetLayerVersionByArnOutput, dest []*lambda.GetLayerVersionByArnOutput, sourcePublic map[int64]bool, destPublic map[int64]bool) []VersionDiff {
+	byVersion := map[int64]*lambda.GetLayerVersionByArnOutput{}
+	for _, d := range dest {
+		byVersion[d.Version] = d
+	}
+
+	var diffs []VersionDiff
+	for _, s := range source {
+		diff := VersionDiff{
+			Version:   s.Version,
+			SourceArn: awsSDK.ToString(s.LayerVersionArn),
+		}
+
+		d, ok := byVersion[s.Version]
+		if !ok {
+			diff.Drift = append(diff.Drift, DriftMissing)
+			diffs = append(diffs, diff)
+			continue
+		}
+		delete(byVersion, s.Version)
+
+		diff.DestinationArn = awsSDK.ToString(d.LayerVersionArn)
+		diff.Drift = versionDrift(s, d)
+
+		if sourcePublic[s.Version] && !destPublic[d.Version] {
+			diff.Drift = append(diff.Drift, DriftNotPublic)
+		}
+
+		diffs = append(diffs, diff)
+	}
+
+	for _, d := range byVersion {
+		diffs = append(diffs, VersionDiff{
+			Version:        d.Version,
+			DestinationArn: awsSDK.ToString(d.LayerVersionArn),
+			Drift:          []string{DriftExtra},
+		})
+	}
+
+	slices.SortFunc(diffs, func(a VersionDiff, b VersionDiff) int {
+		return int(a.Version - b.Version)
+	})
+
+	return diffs
+}
+
+func versionDrift(s *lambda.GetLayerVersionByArnOutput, d *lambda.GetLayerVersionByArnOutput) []string {
+	var drift []string
+
+	if codeSha256(s) != codeSha256(d) {
+		drift = append(drift, DriftCodeSha256)
+	}
+
+	var sourceRuntimes, destRuntimes []string
+	for _, r := range s.CompatibleRuntimes {
+		sourceRuntimes = append(sourceRuntimes, string(r))
+	}
+	for _, r := range d.CompatibleRuntimes {
+		destRuntimes = append(destRuntimes, string(r))
+	}
+	if !sameSet(sourceRuntimes, destRuntimes) {
+		drift = append(drift, DriftRuntimes)
+	}
+
+	var sourceArchitectures, destArchitectures []string
+	for _, a := range s.CompatibleArchitectures {
+		sourceArchitectures = append(sourceArchitectures, string(a))
+	}
+	for _, a := range d.CompatibleArchitectures {
+		destArchitectures = append(destArchitectures, string(a))
+	}
+	if !sameSet(sourceArchitectures, destArchitectures) {
+		drift = append(drift, DriftArchitectures)
+	}
+
+	if awsSDK.ToString(s.LicenseInfo) != awsSDK.ToString(d.LicenseInfo) {
+		drift = append(drift, DriftLicense)
+	}
+
+	if awsSDK.ToString(s.Description) != awsSDK.ToString(d.Description) {
+		drift = append(drift, DriftDescription)
+	}
+
+	return drift
+}
+
+func describeVersions(ctx context.Context, client LambdaClient, layerName string) ([]*lambda.GetLayerVersionByArnOutput, map[int64]bool, error) {
+	listVersions, err := DiscoverVersions(ctx, client, layerName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	versions, err := EnrichVersions(ctx, client, listVersions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	public := map[int64]bool{}
+	for _, v := range versions {
+		policy, err := GetPolicy(ctx, client, layerName, v.Version)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		public[v.Version] = policy.Public()
+	}
+
+	return versions, public, nil
+}
+
+func codeSha256(v *lambda.GetLayerVersionByArnOutput) string {
+	if v.Content == nil {
+		return ""
+	}
+
+	return awsSDK.ToString(v.Content.CodeSha256)
+}
+
+func sameSet(a []string, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+
+	return slices.Equal(a, b)
+}
@@ -0,0 +1,114 @@
+package layers_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestCompareVersions(t *testing.T) {
+	newVersion := func(version int64, sha string) *lambda.GetLayerVersionByArnOutput {
+		return &lambda.GetLayerVersionByArnOutput{
+			Version:                 version,
+			Description:             awsSDK.String("hello"),
+			LicenseInfo:             awsSDK.String("MIT-0"),
+			CompatibleRuntimes:      []types.Runtime{types.RuntimePython312},
+			CompatibleArchitectures: []types.Architecture{types.ArchitectureArm64},
+			Content: &types.LayerVersionContentOutput{
+				CodeSha256: awsSDK.String(sha),
+			},
+		}
+	}
+
+	changed := newVersion(3, "c")
+	changed.LicenseInfo = awsSDK.String("Apache-2.0")
+	changed.CompatibleArchitectures = nil
+
+	diffs := layers.CompareVersions(
+		[]*lambda.GetLayerVersionByArnOutput{newVersion(1, "a"), newVersion(2, "b"), newVersion(3, "c")},
+		[]*lambda.GetLayerVersionByArnOutput{newVersion(1, "a"), changed, newVersion(4, "d")},
+		map[int64]bool{1: true, 2: true, 3: true},
+		map[int64]bool{3: true, 4: true},
+	)
+
+	expected := [][]string{
+		{layers.DriftNotPublic},
+		{layers.DriftMissing},
+		{layers.DriftArchitectures, layers.DriftLicense},
+		{layers.DriftExtra},
+	}
+
+	if len(diffs) != len(expected) {
+		t.Fatalf("wrong number of versions, got: %+v", diffs)
+	}
+
+	for i, d := range diffs {
+		if d.Version != int64(i+1) {
+			t.Errorf("versions are not ordered, got: %d", d.Version)
+		}
+
+		if len(d.Drift) != len(expected[i]) {
+			t.Errorf("wrong drift for version %d, got: %v", d.Version, d.Drift)
+			continue
+		}
+
+		for j := range d.Drift {
+			if d.Drift[j] != expected[i][j] {
+				t.Errorf("wrong drift for version %d, got: %v", d.Version, d.Drift)
+			}
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	newClient := func(versions ...int64) *FakeClient {
+		return &FakeClient{
+			ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+				out := &lambda.ListLayerVersionsOutput{}
+				for _, v := range versions {
+					out.LayerVersions = append(out.LayerVersions, types.LayerVersionsListItem{
+						Version:         v,
+						LayerVersionArn: awsSDK.String("arn:aws:lambda:us-east-1:012345678912:layer:" + *params.LayerName + ":" + strconv.FormatInt(v, 10)),
+					})
+				}
+				return out, nil
+			},
+			GetLayerVersionByArnFn: func(ctx context.Context, params *lambda.GetLayerVersionByArnInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionByArnOutput, error) {
+				version, _ := strconv.ParseInt((*params.Arn)[strings.LastIndex(*params.Arn, ":")+1:], 10, 64)
+				return &lambda.GetLayerVersionByArnOutput{
+					Version:         version,
+					LayerVersionArn: params.Arn,
+					Content:         &types.LayerVersionContentOutput{CodeSha256: awsSDK.String("a")},
+				}, nil
+			},
+			GetLayerVersionPolicyFn: func(ctx context.Context, params *lambda.GetLayerVersionPolicyInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionPolicyOutput, error) {
+				return nil, &types.ResourceNotFoundException{}
+			},
+		}
+	}
+
+	t.Run("Diff missing destination", func(t *testing.T) {
+		diff, err := layers.Diff(context.TODO(), newClient(1, 2), layers.Destination{Region: "eu-west-1", Client: newClient()}, "us-east-1", "source", "dest")
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		if !diff.Drifted() || len(diff.Versions) != 2 || diff.Versions[0].Drift[0] != layers.DriftMissing {
+			t.Errorf("expected every version to be missing, got: %+v", diff.Versions)
+		}
+	})
+
+	t.Run("Diff missing source", func(t *testing.T) {
+		_, err := layers.Diff(context.TODO(), newClient(), layers.Destination{Region: "eu-west-1", Client: newClient(1)}, "us-east-1", "source", "dest")
+		if !errors.Is(err, layers.ErrNoVersions) || !strings.Contains(err.Error(), "source in us-east-1") {
+			t.Errorf("expected a missing source layer to fail, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,276 @@
+package layers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// DefaultMaxPackageSize is the largest package a layer can be published from through S3.
+const DefaultMaxPackageSize = 250 << 20
+
+// DefaultDownloadTimeout bounds a single download attempt.
+const DefaultDownloadTimeout = time.Minute * 2
+
+const (
+	DefaultDownloadRetries = 3
+	DefaultDownloadBackoff = time.Millisecond * 500
+)
+
+// expiryMargin treats a location as expired slightly early, so it doesn't
+// expire halfway through the download.
+const expiryMargin = time.Second * 30
+
+var (
+	ErrPackageTooLarge = errors.New("layer package is larger than the maximum size")
+	ErrPackageMismatch = errors.New("layer package doesn't match the source version")
+	ErrNoLocation      = errors.New("layer version has no package location")
+)
+
+// Downloader fetches layer packages into temporary files, verifying the HTTP
+// status, the size and the SHA-256 before anything is published.
+type Downloader struct {
+	Client  *http.Client
+	Timeout time.Duration
+	MaxSize int64
+	TempDir string
+
+	// Retries is how often a transient failure is retried, waiting a jittered
+	// Backoff that doubles after every attempt.
+	Retries int
+	Backoff time.Duration
+}
+
+func NewDownloader() *Downloader {
+	return &Downloader{
+		Client:  &http.Client{},
+		Timeout: DefaultDownloadTimeout,
+		MaxSize: DefaultMaxPackageSize,
+		Retries: DefaultDownloadRetries,
+		Backoff: DefaultDownloadBackoff,
+	}
+}
+
+// LocationRefresher returns a new presigned location for a version.
+type LocationRefresher func(ctx context.Context) (string, error)
+
+// DownloadVersion downloads and verifies the package of version. Transient
+// failures are retried, an expired or forbidden location is replaced through
+// refresh first when it is set.
+func (d *Downloader) DownloadVersion(ctx context.Context, version *lambda.GetLayerVersionByArnOutput, refresh LocationRefresher) (*Package, error) {
+	location := awsSDK.ToString(version.Content.Location)
+	codeSha256 := awsSDK.ToString(version.Content.CodeSha256)
+
+	if location == "" && refresh == nil {
+		return nil, fmt.Errorf("%s: %w", awsSDK.ToString(version.LayerVersionArn), ErrNoLocation)
+	}
+
+	if refresh != nil && LocationExpired(location, time.Now()) {
+		log.Printf("Refreshing expired location: %s", awsSDK.ToString(version.LayerVersionArn))
+
+		var err error
+		if location, err = refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		pkg, err := d.Download(ctx, location, codeSha256, version.Content.CodeSize)
+		if err == nil {
+			return pkg, nil
+		}
+
+		forbidden := refresh != nil && isForbidden(err)
+		if attempt >= d.Retries || ctx.Err() != nil || !(forbidden || retryable(err)) {
+			return nil, err
+		}
+
+		if forbidden {
+			log.Printf("Refreshing forbidden location: %s", awsSDK.ToString(version.LayerVersionArn))
+
+			if location, err = refresh(ctx); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		delay := d.Backoff << attempt
+		if delay > 0 {
+			delay = rand.N(delay)
+		}
+		log.Printf("Retrying download in %v: %v", delay, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Package is a downloaded layer package, Remove deletes its temporary file.
+type Package struct {
+	Path       string
+	Size       int64
+	CodeSha256 string
+}
+
+func (p *Package) Bytes() ([]byte, error) {
+	return os.ReadFile(p.Path)
+}
+
+func (p *Package) Remove() error {
+	return os.Remove(p.Path)
+}
+
+// Download streams location to a temporary file. codeSha256 is the base64
+// encoded SHA-256 Lambda reports for the version and size its CodeSize, both
+// are skipped when empty.
+func (d *Downloader) Download(ctx context.Context, location string, codeSha256 string, size int64) (*Package, error) {
+	log.Printf("Downloading: %s", location)
+
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", location, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &DownloadError{StatusCode: resp.StatusCode}
+	}
+
+	if d.MaxSize > 0 && resp.ContentLength > d.MaxSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrPackageTooLarge, resp.ContentLength)
+	}
+
+	f, err := os.CreateTemp(d.TempDir, "layer-*.zip")
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, err := d.write(f, resp.Body, codeSha256, size)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	return pkg, nil
+}
+
+func (d *Downloader) write(f *os.File, body io.Reader, codeSha256 string, size int64) (*Package, error) {
+	if d.MaxSize > 0 {
+		body = io.LimitReader(body, d.MaxSize+1)
+	}
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.MaxSize > 0 && n > d.MaxSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrPackageTooLarge, d.MaxSize)
+	}
+
+	if size > 0 && n != size {
+		return nil, fmt.Errorf("%w: got %d bytes, expected %d", ErrPackageMismatch, n, size)
+	}
+
+	sum := base64.StdEncoding.EncodeToString(hash.Sum(nil))
+	if codeSha256 != "" && sum != codeSha256 {
+		return nil, fmt.Errorf("%w: got CodeSha256 %s, expected %s", ErrPackageMismatch, sum, codeSha256)
+	}
+
+	return &Package{
+		Path:       f.Name(),
+		Size:       n,
+		CodeSha256: sum,
+	}, nil
+}
+
+type DownloadError struct {
+	StatusCode int
+}
+
+func (e *DownloadError) Error() string {
+	return fmt.Sprintf("unable to download layer package: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// LocationExpired reports whether a presigned location has expired at now,
+// locations that aren't presigned never expire.
+func LocationExpired(location string, now time.Time) bool {
+	u, err := url.Parse(location)
+	if err != nil {
+		return false
+	}
+
+	q := u.Query()
+	signed, err := time.Parse("20060102T150405Z", q.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	expires, err := strconv.Atoi(q.Get("X-Amz-Expires"))
+	if err != nil {
+		return false
+	}
+
+	return !now.Add(expiryMargin).Before(signed.Add(time.Duration(expires) * time.Second))
+}
+
+func isForbidden(err error) bool {
+	var dErr *DownloadError
+	return errors.As(err, &dErr) && dErr.StatusCode == http.StatusForbidden
+}
+
+// retryable reports whether a download failure is worth another attempt:
+// throttling, server errors, timeouts, reset or refused connections and
+// truncated bodies are. TLS and URL errors are net.Errors as well, but won't
+// go away by trying again.
+func retryable(err error) bool {
+	var dErr *DownloadError
+	if errors.As(err, &dErr) {
+		return dErr.StatusCode == http.StatusTooManyRequests || dErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,254 @@
+package layers_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestDownloader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/expired":
+			rw.WriteHeader(http.StatusForbidden)
+			rw.Write([]byte(`<Error><Code>AccessDenied</Code></Error>`))
+		default:
+			rw.Write([]byte(`OK`))
+		}
+	}))
+	defer server.Close()
+
+	newDownloader := func() *layers.Downloader {
+		d := layers.NewDownloader()
+		d.TempDir = t.TempDir()
+		return d
+	}
+
+	t.Run("Download verified", func(t *testing.T) {
+		pkg, err := newDownloader().Download(context.TODO(), server.URL, okSha256, 2)
+		if err != nil {
+			t.Fatalf("expected to succeed: %v", err)
+		}
+		defer pkg.Remove()
+
+		out, err := pkg.Bytes()
+		if err != nil || string(out) != "OK" {
+			t.Errorf("wrong package: %q, %v", out, err)
+		}
+
+		if pkg.Size != 2 || pkg.CodeSha256 != okSha256 {
+			t.Errorf("wrong package details: %+v", pkg)
+		}
+	})
+
+	t.Run("Download status", func(t *testing.T) {
+		_, err := newDownloader().Download(context.TODO(), server.URL+"/expired", okSha256, 2)
+
+		var dErr *layers.DownloadError
+		if !errors.As(err, &dErr) || dErr.StatusCode != http.StatusForbidden {
+			t.Errorf("expected a 403 download error, got: %v", err)
+		}
+	})
+
+	t.Run("Download sha mismatch", func(t *testing.T) {
+		d := newDownloader()
+		_, err := d.Download(context.TODO(), server.URL, "other", 2)
+		if !errors.Is(err, layers.ErrPackageMismatch) {
+			t.Errorf("expected a mismatch, got: %v", err)
+		}
+
+		if entries, _ := os.ReadDir(d.TempDir); len(entries) != 0 {
+			t.Errorf("expected the temporary file to be removed, got: %d files", len(entries))
+		}
+	})
+
+	t.Run("Download size mismatch", func(t *testing.T) {
+		_, err := newDownloader().Download(context.TODO(), server.URL, okSha256, 3)
+		if !errors.Is(err, layers.ErrPackageMismatch) {
+			t.Errorf("expected a mismatch, got: %v", err)
+		}
+	})
+
+	t.Run("Download too large", func(t *testing.T) {
+		d := newDownloader()
+		d.MaxSize = 1
+
+		_, err := d.Download(context.TODO(), server.URL, "", 0)
+		if !errors.Is(err, layers.ErrPackageTooLarge) {
+			t.Errorf("expected the package to be too large, got: %v", err)
+		}
+	})
+}
+
+func TestDownloadVersion(t *testing.T) {
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests[req.URL.Path]++
+
+		switch {
+		case req.URL.Path == "/flaky" && requests[req.URL.Path] < 3:
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		case req.URL.Path == "/expired":
+			rw.WriteHeader(http.StatusForbidden)
+		case req.URL.Path == "/missing":
+			rw.WriteHeader(http.StatusNotFound)
+		default:
+			rw.Write([]byte(`OK`))
+		}
+	}))
+	defer server.Close()
+
+	newVersion := func(location string) *lambda.GetLayerVersionByArnOutput {
+		return &lambda.GetLayerVersionByArnOutput{
+			LayerVersionArn: awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo:1"),
+			Content: &types.LayerVersionContentOutput{
+				Location:   awsSDK.String(location),
+				CodeSha256: awsSDK.String(okSha256),
+			},
+		}
+	}
+
+	newDownloader := func() *layers.Downloader {
+		d := layers.NewDownloader()
+		d.TempDir = t.TempDir()
+		d.Backoff = time.Millisecond
+		return d
+	}
+
+	refreshed := 0
+	refresh := func(ctx context.Context) (string, error) {
+		refreshed++
+		return server.URL + "/fresh", nil
+	}
+
+	t.Run("DownloadVersion retries transient failures", func(t *testing.T) {
+		pkg, err := newDownloader().DownloadVersion(context.TODO(), newVersion(server.URL+"/flaky"), nil)
+		if err != nil {
+			t.Fatalf("expected to succeed: %v", err)
+		}
+		pkg.Remove()
+
+		if requests["/flaky"] != 3 {
+			t.Errorf("expected 3 attempts, got: %d", requests["/flaky"])
+		}
+	})
+
+	t.Run("DownloadVersion refreshes forbidden location", func(t *testing.T) {
+		refreshed = 0
+
+		pkg, err := newDownloader().DownloadVersion(context.TODO(), newVersion(server.URL+"/expired"), refresh)
+		if err != nil {
+			t.Fatalf("expected to succeed: %v", err)
+		}
+		pkg.Remove()
+
+		if refreshed != 1 {
+			t.Errorf("expected a single refresh, got: %d", refreshed)
+		}
+	})
+
+	t.Run("DownloadVersion refreshes expired location", func(t *testing.T) {
+		refreshed, requests["/expired"] = 0, 0
+
+		signed := time.Now().Add(-time.Hour).UTC().Format("20060102T150405Z")
+		pkg, err := newDownloader().DownloadVersion(context.TODO(), newVersion(server.URL+"/expired?X-Amz-Date="+signed+"&X-Amz-Expires=600"), refresh)
+		if err != nil {
+			t.Fatalf("expected to succeed: %v", err)
+		}
+		pkg.Remove()
+
+		if refreshed != 1 || requests["/expired"] != 0 {
+			t.Errorf("expected to refresh before downloading, refreshed %d, requested %d", refreshed, requests["/expired"])
+		}
+	})
+
+	t.Run("DownloadVersion forbidden without refresh", func(t *testing.T) {
+		requests["/expired"] = 0
+
+		_, err := newDownloader().DownloadVersion(context.TODO(), newVersion(server.URL+"/expired"), nil)
+		if !isStatus(err, http.StatusForbidden) || requests["/expired"] != 1 {
+			t.Errorf("expected a single forbidden attempt, got: %v after %d", err, requests["/expired"])
+		}
+	})
+
+	t.Run("DownloadVersion permanent failure", func(t *testing.T) {
+		_, err := newDownloader().DownloadVersion(context.TODO(), newVersion(server.URL+"/missing"), refresh)
+		if !isStatus(err, http.StatusNotFound) || requests["/missing"] != 1 {
+			t.Errorf("expected a single not found attempt, got: %v after %d", err, requests["/missing"])
+		}
+	})
+}
+
+func TestDownloadNotRetried(t *testing.T) {
+	var connections atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`OK`))
+	}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	d := layers.NewDownloader()
+	d.TempDir = t.TempDir()
+	d.Backoff = time.Millisecond
+
+	t.Run("DownloadVersion unknown certificate authority", func(t *testing.T) {
+		_, err := d.DownloadVersion(context.TODO(), &lambda.GetLayerVersionByArnOutput{
+			LayerVersionArn: awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo:1"),
+			Content: &types.LayerVersionContentOutput{
+				Location: awsSDK.String(server.URL),
+			},
+		}, nil)
+
+		var certErr *tls.CertificateVerificationError
+		if !errors.As(err, &certErr) {
+			t.Fatalf("expected a certificate error, got: %v", err)
+		}
+
+		if n := connections.Load(); n != 1 {
+			t.Errorf("expected a single attempt, got: %d", n)
+		}
+	})
+}
+
+func isStatus(err error, status int) bool {
+	var dErr *layers.DownloadError
+	return errors.As(err, &dErr) && dErr.StatusCode == status
+}
+
+func TestLocationExpired(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]bool{
+		"https://example.com/layer.zip":                                               false,
+		"https://example.com/layer.zip?X-Amz-Date=20240601T115500Z&X-Amz-Expires=600": false,
+		"https://example.com/layer.zip?X-Amz-Date=20240601T115000Z&X-Amz-Expires=600": true,
+		"https://example.com/layer.zip?X-Amz-Date=20240601T115020Z&X-Amz-Expires=600": true,
+		"https://example.com/layer.zip?X-Amz-Date=not-a-date&X-Amz-Expires=600":       false,
+	}
+
+	for location, expected := range tests {
+		if layers.LocationExpired(location, now) != expected {
+			t.Errorf("expected %s expired to be %v", location, expected)
+		}
+	}
+}
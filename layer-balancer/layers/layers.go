@@ -1,14 +1,15 @@
 package layers
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"sort"
-	"time"
+	"os"
+	"sync"
 
 	"github.com/aws-powertools/actions/layer-balancer/aws"
 	"github.com/aws-powertools/actions/layer-balancer/config"
@@ -19,51 +20,498 @@ import (
 )
 
 var (
-	ErrNoVersions = errors.New("no layer versions found")
+	ErrNoVersions   = errors.New("no layer versions found")
+	ErrCrossAccount = errors.New("layer is shared from another account")
 )
 
+type Destination struct {
+	Region  string
+	Client  LambdaClient
+	Staging *Staging
+}
+
+type RegionResult struct {
+	Region  string
+	Copied  int
+	Skipped int
+	// Versions maps every source version handled to its destination version, in source order.
+	Versions []VersionMapping
+	Err      error
+}
+
+type Balancer struct {
+	Config       *config.Config
+	ReadClient   LambdaClient
+	Destinations []Destination
+	// ReadAccount is the account of the read credentials, it is resolved
+	// when first needed if empty.
+	ReadAccount string
+
+	Cache      PackageCache
+	Downloader *Downloader
+	// Packages shares downloads between balancers, every call of
+	// BalanceRegions and Apply uses a store of its own when it is nil.
+	Packages *PackageStore
+
+	mu       sync.Mutex
+	policies map[string]*policyEntry
+
+	// checks are the configs of the clients, resolved by Preflight
+	checks []aws.Check
+}
+
+func NewBalancer(ctx context.Context, cfg *config.Config) (*Balancer, error) {
+	if err := cfg.ValidateRoles(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Endpoints.Validate(); err != nil {
+		return nil, err
+	}
+
+	readCfg, err := aws.NewClientConfigWithRoles(ctx, cfg.ReadRegion, cfg.Endpoints, cfg.ReadRoles(), cfg.RoleOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Balancer{
+		Config:     cfg,
+		ReadClient: readCfg.LambdaClient(),
+		Downloader: NewDownloader(),
+	}
+
+	// import and publish don't read from a region
+	if cfg.ReadRegion != "" {
+		b.checks = append(b.checks, aws.Check{Name: "read", Config: readCfg})
+	}
+
+	// packages are downloaded from the locations the Lambda endpoint hands out
+	if b.Downloader.Client, err = aws.HTTPClient(cfg.Endpoints); err != nil {
+		return nil, err
+	}
+	if cfg.MaxPackageSize > 0 {
+		b.Downloader.MaxSize = cfg.MaxPackageSize
+	}
+	if cfg.DownloadTimeout > 0 {
+		b.Downloader.Timeout = cfg.DownloadTimeout
+	}
+
+	if cfg.CacheDir != "" {
+		cache, err := NewDiskCache(cfg.CacheDir, cfg.CacheSize)
+		if err != nil {
+			return nil, fmt.Errorf("cache directory %s: %w", cfg.CacheDir, err)
+		}
+		b.Cache = cache
+	}
+
+	for _, w := range cfg.WriteRegions {
+		clientCfg, err := aws.NewClientConfigWithRoles(ctx, w.Region, cfg.Endpoints, w.Roles(), cfg.RoleOptions)
+		if err != nil {
+			return nil, err
+		}
+		b.checks = append(b.checks, aws.Check{Name: "write", Config: clientCfg})
+
+		dest := Destination{
+			Region: w.Region,
+			Client: clientCfg.LambdaClient(),
+		}
+
+		if w.StagingBucket != "" {
+			dest.Staging = &Staging{
+				Client:    clientCfg.S3Client(),
+				Bucket:    w.StagingBucket,
+				Threshold: cfg.StagingThreshold,
+			}
+		}
+
+		b.Destinations = append(b.Destinations, dest)
+	}
+
+	return b, nil
+}
+
 // these params are temp and may change eventually
-func Balance(ctx context.Context, cfg *config.Config, layerName string) error {
-	readClient := lambda.NewFromConfig(aws.NewDefaultClientConfig(ctx, cfg.ReadRegion).SDKConfig())
-	writeClient := lambda.NewFromConfig(aws.NewClientConfigWithRole(ctx, cfg.WriteRegion, cfg.WriteRole).SDKConfig())
+func Balance(ctx context.Context, cfg *config.Config, layerName string) ([]RegionResult, error) {
+	b, err := NewBalancer(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Balance(ctx, layerName)
+}
+
+// Preflight resolves the identity of the read and every write config with
+// sts:GetCallerIdentity, so missing credentials or a role that can't be
+// assumed are reported before any Lambda call.
+func (b *Balancer) Preflight(ctx context.Context) ([]aws.CheckResult, error) {
+	return aws.Preflight(ctx, b.checks)
+}
 
-	log.SetPrefix(fmt.Sprintf("DryRun: %v ", cfg.DryRun))
+func (b *Balancer) Balance(ctx context.Context, layerName string) ([]RegionResult, error) {
+	log.SetPrefix(fmt.Sprintf("DryRun: %v ", b.Config.DryRun))
 
-	originalVersions, err := DiscoverVersions(ctx, readClient, layerName)
+	originalVersions, err := DiscoverVersions(ctx, b.ReadClient, layerName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	newVersions, err := DiscoverVersions(ctx, writeClient, layerName)
-	if err != nil && err != ErrNoVersions {
+	enrichedVersions, err := EnrichVersionsConcurrently(ctx, b.ReadClient, originalVersions, b.Config.Parallelism)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Found %d versions of %s", len(enrichedVersions), layerName)
+
+	destName, err := b.DestinationName(layerName)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.BalanceRegions(ctx, destName, enrichedVersions), nil
+}
+
+// checkSourceAccount refuses what Lambda doesn't allow on a layer shared from
+// another account, listing its versions and reading their policies.
+func (b *Balancer) checkSourceAccount(ctx context.Context, source LayerArn) error {
+	mirror := b.Config.Permissions.Mirror && !b.Config.Permissions.Explicit()
+	if source.Version > 0 && !mirror {
+		return nil
+	}
+
+	account, err := b.readAccount(ctx)
+	if err != nil || account == "" || account == source.Account {
 		return err
 	}
 
-	if len(newVersions) > 0 && cfg.StartAt == 1 {
-		return fmt.Errorf("the new layer shouldn't exist, found %d versions for %s", len(newVersions), layerName)
+	if source.Version == 0 {
+		return fmt.Errorf("%w: %s is in account %s, its versions can only be listed by that account, pass a versioned ARN", ErrCrossAccount, source, source.Account)
+	}
+
+	return fmt.Errorf("%w: %s is in account %s, its policy can only be read by that account, set the permissions explicitly instead of mirroring them", ErrCrossAccount, source, source.Account)
+}
+
+// readAccount returns ReadAccount, resolving it from the read config. It is
+// empty when the balancer has no read config.
+func (b *Balancer) readAccount(ctx context.Context) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.ReadAccount != "" {
+		return b.ReadAccount, nil
+	}
+
+	for _, check := range b.checks {
+		if check.Name != "read" {
+			continue
+		}
+
+		identity, err := check.Config.ChainIdentity(ctx)
+		if err != nil {
+			return "", fmt.Errorf("read in %s: %w", check.Config.Region, err)
+		}
+		b.ReadAccount = identity.Account
 	}
 
-	enrichedVersions, err := EnrichVersions(ctx, readClient, originalVersions)
+	return b.ReadAccount, nil
+}
+
+// DestinationName is the name layerName is published as in the write regions.
+func (b *Balancer) DestinationName(layerName string) (string, error) {
+	name, err := b.Config.NameMapping.Map(layerName)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	log.Printf("Found %d versions", len(enrichedVersions))
+	if name != layerName {
+		log.Printf("Mapping: %s -> %s", layerName, name)
+	}
+
+	return name, nil
+}
+
+// BalanceArn copies the layer source identifies, which can be shared from
+// another account, to destName in every destination, or to the mapped source
+// name when destName is empty. A versioned ARN copies only that version. The
+// read client has to be in the region of source.
+func (b *Balancer) BalanceArn(ctx context.Context, source LayerArn, destName string) ([]RegionResult, error) {
+	log.SetPrefix(fmt.Sprintf("DryRun: %v ", b.Config.DryRun))
 
-	for _, v := range enrichedVersions {
-		log.Printf("Processing: %s", *v.LayerVersionArn)
+	if err := b.checkSourceAccount(ctx, source); err != nil {
+		return nil, err
+	}
 
-		if v.Version < cfg.StartAt {
+	var versions []*lambda.GetLayerVersionByArnOutput
+	if source.Version > 0 {
+		version, err := b.ReadClient.GetLayerVersionByArn(ctx, &lambda.GetLayerVersionByArnInput{
+			Arn: awsSDK.String(source.String()),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, version)
+	} else {
+		listVersions, err := DiscoverVersions(ctx, b.ReadClient, source.Unversioned())
+		if err != nil {
+			return nil, err
+		}
+
+		if versions, err = EnrichVersionsConcurrently(ctx, b.ReadClient, listVersions, b.Config.Parallelism); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("Found %d versions of %s", len(versions), source)
+
+	if destName == "" {
+		var err error
+		if destName, err = b.DestinationName(source.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.BalanceRegions(ctx, destName, versions), nil
+}
+
+// BalanceRegions copies an already enriched source history to every destination,
+// running at most Config.Concurrency regions at once. A failing region does not stop the others.
+func (b *Balancer) BalanceRegions(ctx context.Context, layerName string, versions []*lambda.GetLayerVersionByArnOutput) []RegionResult {
+	concurrency := b.Config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	store := b.Packages
+	if store == nil {
+		store = NewPackageStore()
+		defer store.Close()
+	}
+
+	results := make([]RegionResult, len(b.Destinations))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, dest := range b.Destinations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = b.balanceRegion(ctx, dest, layerName, versions, store)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// BalanceRegion copies the source versions that are missing from dest, versions
+// already present with the same content are skipped so a failed run can simply be repeated.
+func (b *Balancer) BalanceRegion(ctx context.Context, dest Destination, layerName string, versions []*lambda.GetLayerVersionByArnOutput) RegionResult {
+	store := b.Packages
+	if store == nil {
+		store = NewPackageStore()
+		defer store.Close()
+	}
+
+	return b.balanceRegion(ctx, dest, layerName, versions, store)
+}
+
+func (b *Balancer) balanceRegion(ctx context.Context, dest Destination, layerName string, versions []*lambda.GetLayerVersionByArnOutput, store *PackageStore) (result RegionResult) {
+	result.Region = dest.Region
+
+	pending, listVersions, skipped, err := b.missingVersions(ctx, dest, layerName, versions)
+	result.Skipped = len(skipped)
+	result.Versions = skipped
+	defer func() { sortMappings(result.Versions) }()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	var align *aligner
+	if b.Config.AlignVersions {
+		align = newAligner(dest, layerName, b.Config.DryRun, LatestVersion(listVersions))
+		defer func() {
+			// leftover placeholders are reported along with what stopped the copy
+			if cleanupErr := align.cleanup(ctx); cleanupErr != nil {
+				result.Err = errors.Join(result.Err, cleanupErr)
+			}
+		}()
+	}
+
+	opts := []CopyOption{WithPackageCache(b.Cache), WithDownloader(b.Downloader), WithStaging(dest.Staging), WithReadClient(b.ReadClient)}
+
+	// packages are downloaded ahead while versions are published one by one in
+	// source order, so destination numbering stays deterministic
+	var packages *pipeline
+	if !b.Config.DryRun {
+		packages = startPipeline(ctx, pending, b.Config.Parallelism, store, newCopyOptions(opts...))
+		defer packages.stop()
+	}
+
+	for i, v := range pending {
+		if align != nil {
+			if err := align.alignTo(ctx, v.Version); err != nil {
+				result.Err = err
+				return result
+			}
+		}
+
+		permissions, err := b.permissions(ctx, v)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+
+		versionOpts := append([]CopyOption{WithPermissions(permissions)}, opts...)
+		if packages != nil {
+			pkg, err := packages.next(ctx, i)
+			if err != nil {
+				result.Err = err
+				return result
+			}
+			versionOpts = append(versionOpts, WithPackageFile(pkg))
+		}
+
+		out, err := Copy(ctx, dest.Client, layerName, v, b.Config.DryRun, versionOpts...)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+
+		if align != nil {
+			if err := align.published(ctx, v.Version, out); err != nil {
+				result.Err = err
+				return result
+			}
+		} else if out != nil && out.Version != v.Version {
+			log.Printf("Version drift: %s published as version %d in %s", *v.LayerVersionArn, out.Version, dest.Region)
+		}
+
+		result.Copied++
+		result.Versions = append(result.Versions, copiedMapping(v, out))
+	}
+
+	return result
+}
+
+// missingVersions returns the source versions from Config.StartAt on that dest
+// has no copy of, in source order, along with the versions dest already has
+// and the mapping of the source versions that were skipped.
+func (b *Balancer) missingVersions(ctx context.Context, dest Destination, layerName string, versions []*lambda.GetLayerVersionByArnOutput) ([]*lambda.GetLayerVersionByArnOutput, []types.LayerVersionsListItem, []VersionMapping, error) {
+	listVersions, err := DiscoverVersions(ctx, dest.Client, layerName)
+	if err != nil && err != ErrNoVersions {
+		return nil, nil, nil, err
+	}
+
+	existing, err := EnrichVersionsConcurrently(ctx, dest.Client, listVersions, b.Config.Parallelism)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	matched, _ := MatchVersions(versions, existing)
+
+	if gaps := FindGaps(versions); len(gaps) > 0 {
+		log.Printf("Source history of %s is missing versions %v", layerName, gaps)
+	}
+
+	var pending []*lambda.GetLayerVersionByArnOutput
+	var skipped []VersionMapping
+	for _, v := range versions {
+		log.Printf("Processing: %s -> %s", *v.LayerVersionArn, dest.Region)
+
+		if v.Version < b.Config.StartAt {
 			log.Printf("Skipping layer version: %d", v.Version)
+			skipped = append(skipped, skippedMapping(v, nil))
+			continue
+		}
+
+		if existing, ok := matched[v.Version]; ok {
+			log.Printf("Already present: %s as %s", *v.LayerVersionArn, *existing.LayerVersionArn)
+			skipped = append(skipped, skippedMapping(v, existing))
 			continue
 		}
 
-		if err := Copy(ctx, writeClient, layerName, v, cfg.DryRun); err != nil {
-			return err
+		pending = append(pending, v)
+	}
+
+	return pending, listVersions, skipped, nil
+}
+
+// permissions returns the statements to add to the copy of version, a source
+// policy is only read once however many regions the version is copied to.
+func (b *Balancer) permissions(ctx context.Context, version *lambda.GetLayerVersionByArnOutput) ([]Permission, error) {
+	p := b.Config.Permissions
+	if p.Explicit() {
+		return ExplicitPermissions(p), nil
+	}
+
+	if !p.Mirror {
+		return []Permission{PublicPermission}, nil
+	}
+
+	arn := awsSDK.ToString(version.LayerVersionArn)
+
+	b.mu.Lock()
+	entry, ok := b.policies[arn]
+	if !ok {
+		entry = &policyEntry{done: make(chan struct{})}
+		if b.policies == nil {
+			b.policies = map[string]*policyEntry{}
+		}
+		b.policies[arn] = entry
+	}
+	b.mu.Unlock()
+
+	if !ok {
+		entry.permissions, entry.err = b.readPermissions(ctx, version)
+		if entry.err != nil {
+			b.mu.Lock()
+			delete(b.policies, arn)
+			b.mu.Unlock()
 		}
+		close(entry.done)
+	}
+
+	select {
+	case <-entry.done:
+		return entry.permissions, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// policyEntry holds the permissions of a source version, regions asking while
+// the policy is read wait for done. A failed read is tried again by the next
+// region asking.
+type policyEntry struct {
+	done        chan struct{}
+	permissions []Permission
+	err         error
+}
+
+// knownPolicy returns an entry of permissions that doesn't need to be read.
+func knownPolicy(permissions []Permission) *policyEntry {
+	done := make(chan struct{})
+	close(done)
+
+	return &policyEntry{done: done, permissions: permissions}
+}
+
+func (b *Balancer) readPermissions(ctx context.Context, version *lambda.GetLayerVersionByArnOutput) ([]Permission, error) {
+	policy, err := GetPolicy(ctx, b.ReadClient, awsSDK.ToString(version.LayerArn), version.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := policy.Permissions()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", awsSDK.ToString(version.LayerVersionArn), err)
 	}
 
-	return nil
+	return permissions, nil
 }
 
 func DiscoverVersions(ctx context.Context, client LambdaClient, name string) ([]types.LayerVersionsListItem, error) {
@@ -101,94 +549,182 @@ func DiscoverVersions(ctx context.Context, client LambdaClient, name string) ([]
 }
 
 func EnrichVersions(ctx context.Context, client LambdaClient, listVersions []types.LayerVersionsListItem) ([]*lambda.GetLayerVersionByArnOutput, error) {
-	var versions []*lambda.GetLayerVersionByArnOutput
-	for _, v := range listVersions {
-		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
-		defer cancel()
+	return EnrichVersionsConcurrently(ctx, client, listVersions, 1)
+}
 
-		version, err := client.GetLayerVersionByArn(ctx, &lambda.GetLayerVersionByArnInput{
-			Arn: v.LayerVersionArn,
-		})
-		if err != nil {
-			return nil, err
-		}
+// DownloadPackage downloads location into memory, it is checked for its HTTP
+// status and size but not verified against a version, use a Downloader for that.
+func DownloadPackage(ctx context.Context, location string) ([]byte, error) {
+	pkg, err := NewDownloader().Download(ctx, location, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	defer pkg.Remove()
 
-		versions = append(versions, version)
+	return pkg.Bytes()
+}
+
+type CopyOptions struct {
+	Cache      PackageCache
+	Downloader *Downloader
+	Staging    *Staging
+	// ReadClient re-fetches the source version when its location has expired.
+	ReadClient LambdaClient
+	// Package is an already downloaded package of the version, PackageFile
+	// the same on disk. Copy removes neither.
+	Package     []byte
+	PackageFile *Package
+
+	// Permissions added to the published version, nil makes it public
+	// while an empty slice adds none.
+	Permissions []Permission
+}
+
+type CopyOption func(o *CopyOptions)
+
+func newCopyOptions(opts ...CopyOption) *CopyOptions {
+	o := &CopyOptions{}
+	for _, opt := range opts {
+		opt(o)
 	}
 
-	sort.Slice(versions, func(i int, j int) bool {
-		return versions[i].Version < versions[j].Version
-	})
+	if o.Downloader == nil {
+		o.Downloader = NewDownloader()
+	}
 
-	return versions, nil
+	return o
 }
 
-func DownloadPackage(ctx context.Context, location string) ([]byte, error) {
-	log.Printf("Downloading: %s", location)
-	req, err := http.NewRequest("GET", location, nil)
-	if err != nil {
-		return nil, err
+func WithPackageCache(cache PackageCache) CopyOption {
+	return func(o *CopyOptions) {
+		o.Cache = cache
 	}
+}
 
-	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
-	defer cancel()
+func WithDownloader(downloader *Downloader) CopyOption {
+	return func(o *CopyOptions) {
+		o.Downloader = downloader
+	}
+}
 
-	req = req.WithContext(ctx)
+func WithPackage(zip []byte) CopyOption {
+	return func(o *CopyOptions) {
+		o.Package = zip
+	}
+}
 
-	resp, err := http.DefaultClient.Do(req)
+func WithPackageFile(pkg *Package) CopyOption {
+	return func(o *CopyOptions) {
+		o.PackageFile = pkg
+	}
+}
+
+func WithReadClient(client LambdaClient) CopyOption {
+	return func(o *CopyOptions) {
+		o.ReadClient = client
+	}
+}
+
+func WithStaging(staging *Staging) CopyOption {
+	return func(o *CopyOptions) {
+		o.Staging = staging
+	}
+}
+
+func WithPermissions(permissions []Permission) CopyOption {
+	return func(o *CopyOptions) {
+		o.Permissions = permissions
+	}
+}
+
+// Copy publishes version under layerName using writeClient and adds its permissions.
+// The publish output is returned even if adding the permission fails, it is nil in dry run mode.
+func Copy(ctx context.Context, writeClient LambdaClient, layerName string, version *lambda.GetLayerVersionByArnOutput, dryRun bool, opts ...CopyOption) (*lambda.PublishLayerVersionOutput, error) {
+	log.Printf("Copying: %s\n", *version.LayerArn)
+
+	o := newCopyOptions(opts...)
+
+	if dryRun {
+		return nil, nil
+	}
+
+	content, cleanup, err := o.content(ctx, layerName, version)
 	if err != nil {
 		return nil, err
 	}
+	defer cleanup()
+
+	out, err := writeClient.PublishLayerVersion(ctx, &lambda.PublishLayerVersionInput{
+		Content:                 content,
+		LayerName:               awsSDK.String(layerName),
+		Description:             version.Description,
+		CompatibleArchitectures: version.CompatibleArchitectures,
+		CompatibleRuntimes:      version.CompatibleRuntimes,
+		LicenseInfo:             version.LicenseInfo,
+	})
 
-	body, err := io.ReadAll(resp.Body)
-	defer resp.Body.Close()
 	if err != nil {
 		return nil, err
 	}
+	permissions := o.Permissions
+	if permissions == nil {
+		permissions = []Permission{PublicPermission}
+	}
 
-	return body, nil
+	if err := AddPermissions(ctx, writeClient, layerName, out.Version, permissions); err != nil {
+		return out, err
+	}
+
+	return out, nil
 }
 
-func Copy(ctx context.Context, writeClient LambdaClient, layerName string, version *lambda.GetLayerVersionByArnOutput, dryRun bool) error {
-	log.Printf("Copying: %s\n", *version.LayerArn)
+// content returns what version is published from. Packages above the staging
+// threshold are streamed from their file to the staging bucket, only smaller
+// ones are read into memory for an inline upload.
+func (o *CopyOptions) content(ctx context.Context, layerName string, version *lambda.GetLayerVersionByArnOutput) (*types.LayerVersionContentInput, func(), error) {
+	if o.Package != nil {
+		if o.Staging.Use(int64(len(o.Package))) {
+			sum := sha256.Sum256(o.Package)
+			return o.Staging.Stage(ctx, layerName, base64.StdEncoding.EncodeToString(sum[:]), bytes.NewReader(o.Package), int64(len(o.Package)))
+		}
 
-	if !dryRun {
-		zip, err := DownloadPackage(ctx, *version.Content.Location)
-		if err != nil {
-			return err
-		}
-
-		out, err := writeClient.PublishLayerVersion(ctx, &lambda.PublishLayerVersionInput{
-			Content: &types.LayerVersionContentInput{
-				ZipFile: zip,
-			},
-			LayerName:               awsSDK.String(layerName),
-			Description:             version.Description,
-			CompatibleArchitectures: version.CompatibleArchitectures,
-			CompatibleRuntimes:      version.CompatibleRuntimes,
-			LicenseInfo:             version.LicenseInfo,
-		})
+		return &types.LayerVersionContentInput{ZipFile: o.Package}, func() {}, nil
+	}
 
-		if err != nil {
-			return err
+	pkg := o.PackageFile
+	if pkg == nil {
+		var err error
+		if pkg, err = fetchPackage(ctx, version, o); err != nil {
+			return nil, nil, err
 		}
-		if _, err = writeClient.AddLayerVersionPermission(ctx, &lambda.AddLayerVersionPermissionInput{
-			LayerName:     awsSDK.String(layerName),
-			VersionNumber: awsSDK.Int64(out.Version),
-			Action:        awsSDK.String("lambda:GetLayerVersion"),
-			Principal:     awsSDK.String("*"),
-			StatementId:   awsSDK.String("PublicLayerAccess"),
-		}); err != nil {
-			return err
+		defer pkg.Remove()
+	}
+
+	if o.Staging.Use(pkg.Size) {
+		f, err := os.Open(pkg.Path)
+		if err != nil {
+			return nil, nil, err
 		}
+		defer f.Close()
+
+		return o.Staging.Stage(ctx, layerName, pkg.CodeSha256, f, pkg.Size)
+	}
+
+	zip, err := pkg.Bytes()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return nil
+	return &types.LayerVersionContentInput{ZipFile: zip}, func() {}, nil
 }
 
 type LambdaClient interface {
+	DeleteLayerVersion(ctx context.Context, params *lambda.DeleteLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.DeleteLayerVersionOutput, error)
+	ListLayers(ctx context.Context, params *lambda.ListLayersInput, optFns ...func(*lambda.Options)) (*lambda.ListLayersOutput, error)
 	ListLayerVersions(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error)
 	GetLayerVersionByArn(ctx context.Context, params *lambda.GetLayerVersionByArnInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionByArnOutput, error)
 	PublishLayerVersion(ctx context.Context, params *lambda.PublishLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishLayerVersionOutput, error)
 	AddLayerVersionPermission(ctx context.Context, params *lambda.AddLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.AddLayerVersionPermissionOutput, error)
+	GetLayerVersionPolicy(ctx context.Context, params *lambda.GetLayerVersionPolicyInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionPolicyOutput, error)
+	RemoveLayerVersionPermission(ctx context.Context, params *lambda.RemoveLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.RemoveLayerVersionPermissionOutput, error)
 }
@@ -5,10 +5,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/aws-powertools/actions/layer-balancer/config"
 	"github.com/aws-powertools/actions/layer-balancer/layers"
 	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
@@ -17,10 +19,19 @@ import (
 )
 
 type FakeClient struct {
+	ListLayersFn                func(ctx context.Context, params *lambda.ListLayersInput, optFns ...func(*lambda.Options)) (*lambda.ListLayersOutput, error)
 	ListLayerVersionsFn         func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error)
 	GetLayerVersionByArnFn      func(ctx context.Context, params *lambda.GetLayerVersionByArnInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionByArnOutput, error)
 	PublishLayerVersionFn       func(ctx context.Context, params *lambda.PublishLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishLayerVersionOutput, error)
 	AddLayerVersionPermissionFn func(ctx context.Context, params *lambda.AddLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.AddLayerVersionPermissionOutput, error)
+	DeleteLayerVersionFn        func(ctx context.Context, params *lambda.DeleteLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.DeleteLayerVersionOutput, error)
+	GetLayerVersionPolicyFn     func(ctx context.Context, params *lambda.GetLayerVersionPolicyInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionPolicyOutput, error)
+
+	RemoveLayerVersionPermissionFn func(ctx context.Context, params *lambda.RemoveLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.RemoveLayerVersionPermissionOutput, error)
+}
+
+func (c *FakeClient) ListLayers(ctx context.Context, params *lambda.ListLayersInput, optFns ...func(*lambda.Options)) (*lambda.ListLayersOutput, error) {
+	return c.ListLayersFn(ctx, params, optFns...)
 }
 
 func (c *FakeClient) ListLayerVersions(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
@@ -39,6 +50,18 @@ func (c *FakeClient) AddLayerVersionPermission(ctx context.Context, params *lamb
 	return c.AddLayerVersionPermissionFn(ctx, params, optFns...)
 }
 
+func (c *FakeClient) DeleteLayerVersion(ctx context.Context, params *lambda.DeleteLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.DeleteLayerVersionOutput, error) {
+	return c.DeleteLayerVersionFn(ctx, params, optFns...)
+}
+
+func (c *FakeClient) GetLayerVersionPolicy(ctx context.Context, params *lambda.GetLayerVersionPolicyInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionPolicyOutput, error) {
+	return c.GetLayerVersionPolicyFn(ctx, params, optFns...)
+}
+
+func (c *FakeClient) RemoveLayerVersionPermission(ctx context.Context, params *lambda.RemoveLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.RemoveLayerVersionPermissionOutput, error) {
+	return c.RemoveLayerVersionPermissionFn(ctx, params, optFns...)
+}
+
 func TestDiscoverVersions(t *testing.T) {
 	emptyClient := &FakeClient{
 		ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
@@ -325,7 +348,7 @@ func TestCopy(t *testing.T) {
 			},
 		}
 
-		if err := layers.Copy(context.TODO(), client, "foo", version, false); err == nil {
+		if _, err := layers.Copy(context.TODO(), client, "foo", version, false); err == nil {
 			t.Errorf("excepted failure, but none returned")
 		} else {
 			_, ok := err.(*url.Error)
@@ -358,7 +381,7 @@ func TestCopy(t *testing.T) {
 			},
 		}
 
-		if err := layers.Copy(context.TODO(), client, "foo", version, false); err == nil {
+		if _, err := layers.Copy(context.TODO(), client, "foo", version, false); err == nil {
 			t.Errorf("excepted failure, but none returned")
 		} else {
 			smithyErr, ok := err.(*smithy.OperationError)
@@ -393,7 +416,7 @@ func TestCopy(t *testing.T) {
 			},
 		}
 
-		if err := layers.Copy(context.TODO(), client, "foo", version, false); err == nil {
+		if _, err := layers.Copy(context.TODO(), client, "foo", version, false); err == nil {
 			t.Errorf("excepted failure, but none returned")
 		} else {
 			smithyErr, ok := err.(*smithy.OperationError)
@@ -403,3 +426,248 @@ func TestCopy(t *testing.T) {
 		}
 	})
 }
+
+// okSha256 is the CodeSha256 of the `OK` package the test servers respond with.
+const okSha256 = "VlM5vE0z1ygXtYMCQRLrf1zfPl7vAlLW7BucmpThK7M="
+
+func TestBalanceRegions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`OK`))
+	}))
+	defer server.Close()
+
+	newClient := func(listErr error) *FakeClient {
+		return &FakeClient{
+			ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+				if listErr != nil {
+					return nil, listErr
+				}
+
+				return &lambda.ListLayerVersionsOutput{}, nil
+			},
+			PublishLayerVersionFn: func(ctx context.Context, params *lambda.PublishLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishLayerVersionOutput, error) {
+				return &lambda.PublishLayerVersionOutput{
+					Version: 1,
+				}, nil
+			},
+			AddLayerVersionPermissionFn: func(ctx context.Context, params *lambda.AddLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.AddLayerVersionPermissionOutput, error) {
+				return nil, nil
+			},
+		}
+	}
+
+	versions := []*lambda.GetLayerVersionByArnOutput{
+		{
+			Version:         1,
+			LayerArn:        awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo"),
+			LayerVersionArn: awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo:1"),
+			Content: &types.LayerVersionContentOutput{
+				Location: awsSDK.String(server.URL),
+			},
+		},
+	}
+
+	t.Run("BalanceRegions per region results", func(t *testing.T) {
+		cfg := config.NewConfig(config.WithStartAt(1), config.WithConcurrency(1))
+		cfg.DryRun = false
+
+		balancer := &layers.Balancer{
+			Config: cfg,
+			Destinations: []layers.Destination{
+				{Region: "eu-west-1", Client: newClient(nil)},
+				{Region: "eu-west-2", Client: newClient(&smithy.OperationError{
+					ServiceID:     "Lambda",
+					OperationName: "ListLayerVersions",
+				})},
+				{Region: "eu-west-3", Client: newClient(nil)},
+			},
+		}
+
+		results := balancer.BalanceRegions(context.TODO(), "foo", versions)
+
+		if len(results) != 3 {
+			t.Fatalf("expected a result per region, got: %d", len(results))
+		}
+
+		for _, r := range results {
+			if r.Region == "eu-west-2" && r.Err == nil {
+				t.Errorf("expected eu-west-2 to fail")
+			}
+
+			if r.Region != "eu-west-2" && r.Err != nil {
+				t.Errorf("expected %s to succeed: %v", r.Region, r.Err)
+			}
+		}
+	})
+
+	t.Run("BalanceRegions shared cache", func(t *testing.T) {
+		downloads := 0
+		counting := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			downloads++
+			rw.Write([]byte(`OK`))
+		}))
+		defer counting.Close()
+
+		cfg := config.NewConfig(config.WithStartAt(1), config.WithConcurrency(1))
+		cfg.DryRun = false
+
+		balancer := &layers.Balancer{
+			Config: cfg,
+			Destinations: []layers.Destination{
+				{Region: "eu-west-1", Client: newClient(nil)},
+				{Region: "eu-west-2", Client: newClient(nil)},
+			},
+			Cache: layers.NewMemoryCache(layers.DefaultMemoryCacheSize),
+		}
+
+		balancer.BalanceRegions(context.TODO(), "foo", []*lambda.GetLayerVersionByArnOutput{
+			{
+				Version:         1,
+				LayerArn:        awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo"),
+				LayerVersionArn: awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo:1"),
+				Content: &types.LayerVersionContentOutput{
+					Location:   awsSDK.String(counting.URL),
+					CodeSha256: awsSDK.String(okSha256),
+				},
+			},
+		})
+
+		if downloads != 1 {
+			t.Errorf("expected a single download, got: %d", downloads)
+		}
+	})
+
+	t.Run("BalanceRegions resume", func(t *testing.T) {
+		source := []*lambda.GetLayerVersionByArnOutput{
+			{
+				Version:         1,
+				LayerArn:        awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo"),
+				LayerVersionArn: awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo:1"),
+				Content: &types.LayerVersionContentOutput{
+					Location:   awsSDK.String(server.URL),
+					CodeSha256: awsSDK.String("one"),
+				},
+			},
+			{
+				Version:         2,
+				LayerArn:        awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo"),
+				LayerVersionArn: awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo:2"),
+				Content: &types.LayerVersionContentOutput{
+					Location:   awsSDK.String(server.URL),
+					CodeSha256: awsSDK.String(okSha256),
+				},
+			},
+		}
+
+		published := 0
+		client := newClient(nil)
+		client.ListLayerVersionsFn = func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+			return &lambda.ListLayerVersionsOutput{
+				LayerVersions: []types.LayerVersionsListItem{
+					{
+						Version:         1,
+						LayerVersionArn: awsSDK.String("arn:aws:lambda:eu-west-1:012345678912:layer:foo:1"),
+					},
+				},
+			}, nil
+		}
+		client.GetLayerVersionByArnFn = func(ctx context.Context, params *lambda.GetLayerVersionByArnInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionByArnOutput, error) {
+			return &lambda.GetLayerVersionByArnOutput{
+				Version:         1,
+				LayerVersionArn: params.Arn,
+				Content: &types.LayerVersionContentOutput{
+					CodeSha256: awsSDK.String("one"),
+				},
+			}, nil
+		}
+		client.PublishLayerVersionFn = func(ctx context.Context, params *lambda.PublishLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishLayerVersionOutput, error) {
+			published++
+			return &lambda.PublishLayerVersionOutput{
+				Version:         2,
+				LayerVersionArn: awsSDK.String("arn:aws:lambda:eu-west-1:012345678912:layer:foo:2"),
+			}, nil
+		}
+
+		cfg := config.NewConfig(config.WithStartAt(1))
+		cfg.DryRun = false
+
+		balancer := &layers.Balancer{
+			Config: cfg,
+			Destinations: []layers.Destination{
+				{Region: "eu-west-1", Client: client},
+			},
+		}
+
+		results := balancer.BalanceRegions(context.TODO(), "foo", source)
+
+		if results[0].Err != nil {
+			t.Fatalf("expected no errors: %v", results[0].Err)
+		}
+
+		if published != 1 || results[0].Copied != 1 || results[0].Skipped != 1 {
+			t.Errorf("expected only the missing version to be copied, got: %+v", results[0])
+		}
+
+		expected := []layers.VersionMapping{
+			{Version: 1, SourceArn: "arn:aws:lambda:region:012345678912:layer:foo:1", DestinationArn: "arn:aws:lambda:eu-west-1:012345678912:layer:foo:1", CodeSha256: "one", Status: layers.VersionSkipped},
+			{Version: 2, SourceArn: "arn:aws:lambda:region:012345678912:layer:foo:2", DestinationArn: "arn:aws:lambda:eu-west-1:012345678912:layer:foo:2", CodeSha256: okSha256, Status: layers.VersionCopied},
+		}
+		if !reflect.DeepEqual(results[0].Versions, expected) {
+			t.Errorf("expected every version to be mapped, got: %+v", results[0].Versions)
+		}
+	})
+}
+
+func TestResolveLayers(t *testing.T) {
+	needsMarker := true
+	client := &FakeClient{
+		ListLayersFn: func(ctx context.Context, params *lambda.ListLayersInput, optFns ...func(*lambda.Options)) (*lambda.ListLayersOutput, error) {
+			if needsMarker {
+				needsMarker = false
+				return &lambda.ListLayersOutput{
+					Layers: []types.LayersListItem{
+						{LayerName: awsSDK.String("AWSLambdaPowertoolsPythonV3-python312-arm64")},
+						{LayerName: awsSDK.String("AWSLambdaPowertoolsPythonV3-python312-x86_64")},
+					},
+					NextMarker: awsSDK.String("foobar"),
+				}, nil
+			}
+
+			return &lambda.ListLayersOutput{
+				Layers: []types.LayersListItem{
+					{LayerName: awsSDK.String("AWSLambdaPowertoolsPythonV2-x86_64")},
+					{LayerName: awsSDK.String("other")},
+				},
+			}, nil
+		},
+	}
+
+	t.Run("ResolveLayers names only", func(t *testing.T) {
+		out, err := layers.ResolveLayers(context.TODO(), &FakeClient{}, []string{"b", "a", "b"}, "", "")
+		if err != nil {
+			t.Errorf("expected no errors: %v", err)
+		}
+
+		if strings.Join(out, ",") != "a,b" {
+			t.Errorf("wrong layers returned, got: %v", out)
+		}
+	})
+
+	t.Run("ResolveLayers prefix and glob", func(t *testing.T) {
+		out, err := layers.ResolveLayers(context.TODO(), client, []string{"extra"}, "AWSLambdaPowertoolsPythonV2", "*-python312-x86_64")
+		if err != nil {
+			t.Errorf("expected no errors: %v", err)
+		}
+
+		expected := "AWSLambdaPowertoolsPythonV2-x86_64,AWSLambdaPowertoolsPythonV3-python312-x86_64,extra"
+		if strings.Join(out, ",") != expected {
+			t.Errorf("wrong layers returned, got: %v", out)
+		}
+	})
+
+	t.Run("ResolveLayers bad glob", func(t *testing.T) {
+		if _, err := layers.ResolveLayers(context.TODO(), client, nil, "", "["); err == nil {
+			t.Errorf("expected errors")
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package layers
+
+import (
+	"cmp"
+	"slices"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+type VersionStatus string
+
+const (
+	VersionCopied VersionStatus = "copied"
+	// VersionSkipped is a version already present in the destination, or
+	// before Config.StartAt when it has no DestinationArn.
+	VersionSkipped VersionStatus = "skipped"
+	// VersionPending is a version a dry run would have copied.
+	VersionPending VersionStatus = "pending"
+)
+
+// VersionMapping ties a source version to the destination version holding it.
+type VersionMapping struct {
+	Version        int64         `json:"version"`
+	SourceArn      string        `json:"sourceArn"`
+	DestinationArn string        `json:"destinationArn,omitempty"`
+	CodeSha256     string        `json:"codeSha256"`
+	Status         VersionStatus `json:"status"`
+}
+
+// ArnMapping is a VersionMapping along with the layer and region it belongs to.
+type ArnMapping struct {
+	LayerName string `json:"layerName"`
+	Region    string `json:"region"`
+	VersionMapping
+}
+
+// ArnMappings flattens the version mappings of results, failed regions
+// contribute the versions handled before they failed.
+func ArnMappings(results []LayerResult) []ArnMapping {
+	var mappings []ArnMapping
+	for _, r := range results {
+		for _, region := range r.Regions {
+			for _, v := range region.Versions {
+				mappings = append(mappings, ArnMapping{
+					LayerName:      r.LayerName,
+					Region:         region.Region,
+					VersionMapping: v,
+				})
+			}
+		}
+	}
+
+	return mappings
+}
+
+func skippedMapping(version *lambda.GetLayerVersionByArnOutput, existing *lambda.GetLayerVersionByArnOutput) VersionMapping {
+	m := newMapping(version, VersionSkipped)
+	if existing != nil {
+		m.DestinationArn = awsSDK.ToString(existing.LayerVersionArn)
+	}
+
+	return m
+}
+
+// copiedMapping maps version to out, which is nil in dry run mode.
+func copiedMapping(version *lambda.GetLayerVersionByArnOutput, out *lambda.PublishLayerVersionOutput) VersionMapping {
+	if out == nil {
+		return newMapping(version, VersionPending)
+	}
+
+	m := newMapping(version, VersionCopied)
+	m.DestinationArn = awsSDK.ToString(out.LayerVersionArn)
+
+	return m
+}
+
+func newMapping(version *lambda.GetLayerVersionByArnOutput, status VersionStatus) VersionMapping {
+	m := VersionMapping{
+		Version:   version.Version,
+		SourceArn: awsSDK.ToString(version.LayerVersionArn),
+		Status:    status,
+	}
+	if version.Content != nil {
+		m.CodeSha256 = awsSDK.ToString(version.Content.CodeSha256)
+	}
+
+	return m
+}
+
+func sortMappings(mappings []VersionMapping) {
+	slices.SortStableFunc(mappings, func(a, b VersionMapping) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+}
@@ -0,0 +1,194 @@
+package layers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// FileCache is a PackageCache that streams packages from and to files, so
+// large packages are never held in memory.
+type FileCache interface {
+	PackageCache
+	// Open returns the package stored under key, it is verified by the reader.
+	Open(key string) (io.ReadCloser, bool)
+	// PutFile stores the package at path under key.
+	PutFile(key string, path string)
+}
+
+// PackageStore shares package downloads between write regions. The first
+// region to ask for a CodeSha256 downloads it, regions asking while it is
+// downloading wait for the same file. Packages stay on disk until Close, so a
+// region that gets to a version later doesn't download it again.
+type PackageStore struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	packages map[string]*storedPackage
+}
+
+type storedPackage struct {
+	done chan struct{}
+	pkg  *Package
+	err  error
+}
+
+func NewPackageStore() *PackageStore {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &PackageStore{
+		ctx:      ctx,
+		cancel:   cancel,
+		packages: map[string]*storedPackage{},
+	}
+}
+
+// get returns the package of version, fetching it with o when no region did
+// yet. The package belongs to the store and must not be removed. Downloads
+// aren't tied to ctx, as other regions may be waiting for them, a failed
+// download is tried again by the next region asking.
+func (s *PackageStore) get(ctx context.Context, version *lambda.GetLayerVersionByArnOutput, o *CopyOptions) (*Package, error) {
+	key := awsSDK.ToString(version.Content.CodeSha256)
+	if key == "" {
+		key = awsSDK.ToString(version.LayerVersionArn)
+	}
+
+	s.mu.Lock()
+	stored, ok := s.packages[key]
+	if !ok {
+		stored = &storedPackage{done: make(chan struct{})}
+		s.packages[key] = stored
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+
+			stored.pkg, stored.err = fetchPackage(s.ctx, version, o)
+			if stored.err != nil {
+				s.mu.Lock()
+				delete(s.packages, key)
+				s.mu.Unlock()
+			}
+			close(stored.done)
+		}()
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-stored.done:
+		return stored.pkg, stored.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close cancels the downloads still running and removes every package.
+func (s *PackageStore) Close() {
+	s.cancel()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, stored := range s.packages {
+		if stored.pkg != nil {
+			stored.pkg.Remove()
+		}
+		delete(s.packages, key)
+	}
+}
+
+// fetchPackage returns the verified package of version as a temporary file
+// owned by the caller, from the cache when possible.
+func fetchPackage(ctx context.Context, version *lambda.GetLayerVersionByArnOutput, o *CopyOptions) (*Package, error) {
+	codeSha256 := awsSDK.ToString(version.Content.CodeSha256)
+	if o.Cache != nil && codeSha256 != "" {
+		if pkg, ok := cachedPackage(o, codeSha256); ok {
+			return pkg, nil
+		}
+	}
+
+	var refresh LocationRefresher
+	if o.ReadClient != nil {
+		refresh = func(ctx context.Context) (string, error) {
+			out, err := o.ReadClient.GetLayerVersionByArn(ctx, &lambda.GetLayerVersionByArnInput{
+				Arn: version.LayerVersionArn,
+			})
+			if err != nil {
+				return "", fmt.Errorf("unable to refresh %s: %w", awsSDK.ToString(version.LayerVersionArn), err)
+			}
+
+			return awsSDK.ToString(out.Content.Location), nil
+		}
+	}
+
+	pkg, err := o.Downloader.DownloadVersion(ctx, version, refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Cache != nil && codeSha256 != "" {
+		if fc, ok := o.Cache.(FileCache); ok {
+			fc.PutFile(codeSha256, pkg.Path)
+		} else if zip, err := pkg.Bytes(); err == nil {
+			o.Cache.Put(codeSha256, zip)
+		}
+	}
+
+	return pkg, nil
+}
+
+func cachedPackage(o *CopyOptions, codeSha256 string) (*Package, bool) {
+	fc, ok := o.Cache.(FileCache)
+	if !ok {
+		zip, ok := o.Cache.Get(codeSha256)
+		if !ok {
+			return nil, false
+		}
+
+		pkg, err := o.Downloader.newPackage(bytes.NewReader(zip), codeSha256)
+		return pkg, err == nil
+	}
+
+	r, ok := fc.Open(codeSha256)
+	if !ok {
+		return nil, false
+	}
+	defer r.Close()
+
+	pkg, err := o.Downloader.newPackage(r, codeSha256)
+	if err != nil {
+		log.Printf("Ignoring cached package %s: %v", codeSha256, err)
+		return nil, false
+	}
+
+	return pkg, true
+}
+
+// newPackage writes r to a temporary file, verifying it against codeSha256.
+func (d *Downloader) newPackage(r io.Reader, codeSha256 string) (*Package, error) {
+	f, err := os.CreateTemp(d.TempDir, "layer-*.zip")
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, err := d.write(f, r, codeSha256, 0)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	return pkg, nil
+}
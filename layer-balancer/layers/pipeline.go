@@ -0,0 +1,175 @@
+package layers
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// EnrichVersionsConcurrently is EnrichVersions with up to parallelism
+// GetLayerVersionByArn calls at once, the first error cancels the rest.
+func EnrichVersionsConcurrently(ctx context.Context, client LambdaClient, listVersions []types.LayerVersionsListItem, parallelism int) ([]*lambda.GetLayerVersionByArnOutput, error) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	versions := make([]*lambda.GetLayerVersionByArnOutput, len(listVersions))
+	sem := make(chan struct{}, parallelism)
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i, v := range listVersions {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			callCtx, callCancel := context.WithTimeout(ctx, time.Second*5)
+			defer callCancel()
+
+			version, err := client.GetLayerVersionByArn(callCtx, &lambda.GetLayerVersionByArnInput{
+				Arn: v.LayerVersionArn,
+			})
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+
+			versions[i] = version
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(versions, func(i int, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+
+	return versions, nil
+}
+
+type fetchResult struct {
+	pkg *Package
+	err error
+}
+
+// pipeline downloads the packages of versions ahead of the publisher, at most
+// parallelism packages are downloading or waiting to be published at once.
+// Packages are handed out strictly in the order of versions, the publisher
+// stops at the first failed one and stop cancels whatever is still running.
+//
+// With a store the packages belong to it and are shared with the other
+// regions, without one they belong to the publisher, which removes them once
+// they are used.
+type pipeline struct {
+	results []chan fetchResult
+	window  chan struct{}
+	store   *PackageStore
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func startPipeline(ctx context.Context, versions []*lambda.GetLayerVersionByArnOutput, parallelism int, store *PackageStore, opts *CopyOptions) *pipeline {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p := &pipeline{
+		results: make([]chan fetchResult, len(versions)),
+		window:  make(chan struct{}, parallelism),
+		store:   store,
+		cancel:  cancel,
+	}
+	for i := range p.results {
+		// buffered so downloads never block on a publisher that stopped early
+		p.results[i] = make(chan fetchResult, 1)
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		for i, v := range versions {
+			select {
+			case p.window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			p.wg.Add(1)
+			go func() {
+				defer p.wg.Done()
+
+				var r fetchResult
+				if store != nil {
+					r.pkg, r.err = store.get(ctx, v, opts)
+				} else {
+					r.pkg, r.err = fetchPackage(ctx, v, opts)
+				}
+				p.results[i] <- r
+			}()
+		}
+	}()
+
+	return p
+}
+
+// next waits for the package of the i-th version, it has to be called in order.
+func (p *pipeline) next(ctx context.Context, i int) (*Package, error) {
+	select {
+	case r := <-p.results[i]:
+		<-p.window
+		return r.pkg, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// stop cancels the downloads still running and waits for them to finish,
+// removing the packages that were never handed out.
+func (p *pipeline) stop() {
+	p.cancel()
+	p.wg.Wait()
+
+	if p.store != nil {
+		return
+	}
+
+	for _, results := range p.results {
+		select {
+		case r := <-results:
+			if r.pkg != nil {
+				r.pkg.Remove()
+			}
+		default:
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package layers_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestEnrichVersionsConcurrently(t *testing.T) {
+	var listVersions []types.LayerVersionsListItem
+	for i := 5; i > 0; i-- {
+		listVersions = append(listVersions, types.LayerVersionsListItem{
+			Version:         int64(i),
+			LayerVersionArn: awsSDK.String(fmt.Sprintf("arn:aws:lambda:region:012345678912:layer:foo:%d", i)),
+		})
+	}
+
+	newClient := func(failing int64) *FakeClient {
+		return &FakeClient{
+			GetLayerVersionByArnFn: func(ctx context.Context, params *lambda.GetLayerVersionByArnInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionByArnOutput, error) {
+				arn := awsSDK.ToString(params.Arn)
+				version, _ := strconv.ParseInt(arn[strings.LastIndex(arn, ":")+1:], 10, 64)
+				if version == failing {
+					return nil, errors.New("throttled")
+				}
+
+				return &lambda.GetLayerVersionByArnOutput{
+					Version:         version,
+					LayerVersionArn: params.Arn,
+				}, nil
+			},
+		}
+	}
+
+	t.Run("EnrichVersionsConcurrently sorted", func(t *testing.T) {
+		versions, err := layers.EnrichVersionsConcurrently(context.TODO(), newClient(0), listVersions, 3)
+		if err != nil {
+			t.Fatalf("expected to succeed: %v", err)
+		}
+
+		for i, v := range versions {
+			if v.Version != int64(i+1) {
+				t.Errorf("expected version %d at %d, got: %d", i+1, i, v.Version)
+			}
+		}
+	})
+
+	t.Run("EnrichVersionsConcurrently error", func(t *testing.T) {
+		_, err := layers.EnrichVersionsConcurrently(context.TODO(), newClient(3), listVersions, 3)
+		if err == nil || err.Error() != "throttled" {
+			t.Errorf("expected the failing call's error, got: %v", err)
+		}
+	})
+}
+
+func TestBalanceRegionPipeline(t *testing.T) {
+	// later versions download faster, so they finish before earlier ones
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		version, _ := strconv.Atoi(strings.TrimPrefix(req.URL.Path, "/"))
+		if version == 0 {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		time.Sleep(time.Duration(10-version) * 5 * time.Millisecond)
+		rw.Write([]byte(fmt.Sprintf("package-%d", version)))
+	}))
+	defer server.Close()
+
+	newVersions := func(failing int) []*lambda.GetLayerVersionByArnOutput {
+		var versions []*lambda.GetLayerVersionByArnOutput
+		for i := 1; i <= 6; i++ {
+			path := strconv.Itoa(i)
+			if i == failing {
+				path = "missing"
+			}
+
+			versions = append(versions, &lambda.GetLayerVersionByArnOutput{
+				Version:         int64(i),
+				LayerArn:        awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo"),
+				LayerVersionArn: awsSDK.String(fmt.Sprintf("arn:aws:lambda:region:012345678912:layer:foo:%d", i)),
+				Description:     awsSDK.String(strconv.Itoa(i)),
+				Content: &types.LayerVersionContentOutput{
+					Location:   awsSDK.String(server.URL + "/" + path),
+					CodeSha256: awsSDK.String(codeSha256(fmt.Sprintf("package-%d", i))),
+				},
+			})
+		}
+		return versions
+	}
+
+	newClient := func(published *[]string) *FakeClient {
+		var mu sync.Mutex
+		return &FakeClient{
+			ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+				return &lambda.ListLayerVersionsOutput{}, nil
+			},
+			PublishLayerVersionFn: func(ctx context.Context, params *lambda.PublishLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishLayerVersionOutput, error) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				if string(params.Content.ZipFile) != "package-"+*params.Description {
+					return nil, fmt.Errorf("version %s published with %q", *params.Description, params.Content.ZipFile)
+				}
+
+				*published = append(*published, *params.Description)
+				return &lambda.PublishLayerVersionOutput{Version: int64(len(*published))}, nil
+			},
+			AddLayerVersionPermissionFn: func(ctx context.Context, params *lambda.AddLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.AddLayerVersionPermissionOutput, error) {
+				return nil, nil
+			},
+		}
+	}
+
+	newBalancer := func(client *FakeClient) *layers.Balancer {
+		cfg := config.NewConfig(config.WithParallelism(3))
+		cfg.DryRun = false
+
+		return &layers.Balancer{
+			Config:       cfg,
+			Destinations: []layers.Destination{{Region: "eu-west-1", Client: client}},
+		}
+	}
+
+	t.Run("BalanceRegion publishes in order", func(t *testing.T) {
+		var published []string
+		client := newClient(&published)
+
+		result := newBalancer(client).BalanceRegion(context.TODO(), layers.Destination{Region: "eu-west-1", Client: client}, "foo", newVersions(0))
+		if result.Err != nil {
+			t.Fatalf("expected to succeed: %v", result.Err)
+		}
+
+		if strings.Join(published, ",") != "1,2,3,4,5,6" {
+			t.Errorf("expected versions in source order, got: %v", published)
+		}
+	})
+
+	t.Run("BalanceRegion stops at the first failure", func(t *testing.T) {
+		var published []string
+		client := newClient(&published)
+
+		result := newBalancer(client).BalanceRegion(context.TODO(), layers.Destination{Region: "eu-west-1", Client: client}, "foo", newVersions(3))
+		if !isStatus(result.Err, http.StatusNotFound) {
+			t.Errorf("expected the download failure, got: %v", result.Err)
+		}
+
+		if strings.Join(published, ",") != "1,2" || result.Copied != 2 {
+			t.Errorf("expected only the versions before the failure, got: %v", published)
+		}
+	})
+
+	t.Run("BalanceRegions downloads each package once", func(t *testing.T) {
+		var mu sync.Mutex
+		downloads := map[string]int{}
+		counting := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			downloads[req.URL.Path]++
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+			rw.Write([]byte("package-" + strings.TrimPrefix(req.URL.Path, "/")))
+		}))
+		defer counting.Close()
+
+		versions := newVersions(0)
+		for _, v := range versions {
+			v.Content.Location = awsSDK.String(fmt.Sprintf("%s/%d", counting.URL, v.Version))
+		}
+
+		var published [3][]string
+		balancer := newBalancer(nil)
+		balancer.Config.Concurrency = 3
+		balancer.Destinations = nil
+		for i, region := range []string{"eu-west-1", "eu-central-1", "us-east-1"} {
+			balancer.Destinations = append(balancer.Destinations, layers.Destination{Region: region, Client: newClient(&published[i])})
+		}
+
+		for _, result := range balancer.BalanceRegions(context.TODO(), "foo", versions) {
+			if result.Err != nil || result.Copied != 6 {
+				t.Errorf("expected %s to copy every version, got: %+v", result.Region, result)
+			}
+		}
+
+		if len(downloads) != 6 {
+			t.Errorf("expected every package to be downloaded, got: %v", downloads)
+		}
+		for path, n := range downloads {
+			if n != 1 {
+				t.Errorf("expected %s to be downloaded once, got: %d", path, n)
+			}
+		}
+	})
+}
@@ -0,0 +1,270 @@
+package layers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+var (
+	ErrPlanStale = errors.New("plan is stale")
+	ErrPlanAlign = errors.New("version alignment can't be planned, run without -align-versions")
+)
+
+// Plan is every copy a run would make, written by plan and executed as is by apply.
+type Plan struct {
+	CreatedAt time.Time   `json:"createdAt"`
+	Layers    []LayerPlan `json:"layers"`
+}
+
+// LayerPlan holds the versions of one source layer to publish in one write
+// region, along with the state both sides were in when it was made.
+type LayerPlan struct {
+	ReadRegion    string   `json:"readRegion"`
+	ReadRole      string   `json:"readRole,omitempty"`
+	ReadRoleChain []string `json:"readRoleChain,omitempty"`
+	SourceLayer   string   `json:"sourceLayer"`
+	Region        string   `json:"region"`
+	Role          string   `json:"role,omitempty"`
+	RoleChain     []string `json:"roleChain,omitempty"`
+	StagingBucket string   `json:"stagingBucket,omitempty"`
+	LayerName     string   `json:"layerName"`
+	// LatestVersion is the latest destination version when planned, 0 when
+	// the layer did not exist yet.
+	LatestVersion int64            `json:"latestVersion"`
+	Versions      []PlannedVersion `json:"versions"`
+}
+
+type PlannedVersion struct {
+	SourceArn  string `json:"sourceArn"`
+	CodeSha256 string `json:"codeSha256"`
+	// ExpectedVersion is the number Lambda should give the copy. Versions
+	// deleted from the destination still use up numbers, so it can be higher.
+	ExpectedVersion int64        `json:"expectedVersion"`
+	Permissions     []Permission `json:"permissions"`
+}
+
+type ApplyResult struct {
+	LayerName string
+	Region    string
+	// Published holds the version ARNs of the copies, in plan order.
+	Published []string
+	Err       error
+}
+
+// Plan works out what Balance would copy for layerName without changing
+// anything. Destinations that are up to date are left out.
+func (b *Balancer) Plan(ctx context.Context, layerName string) ([]LayerPlan, error) {
+	if b.Config.AlignVersions {
+		return nil, ErrPlanAlign
+	}
+
+	listVersions, err := DiscoverVersions(ctx, b.ReadClient, layerName)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := EnrichVersionsConcurrently(ctx, b.ReadClient, listVersions, b.Config.Parallelism)
+	if err != nil {
+		return nil, err
+	}
+
+	destName, err := b.DestinationName(layerName)
+	if err != nil {
+		return nil, err
+	}
+
+	var plans []LayerPlan
+	for _, dest := range b.Destinations {
+		plan, err := b.planRegion(ctx, dest, destName, versions)
+		if err != nil {
+			return nil, fmt.Errorf("%s in %s: %w", layerName, dest.Region, err)
+		}
+
+		if len(plan.Versions) > 0 {
+			plans = append(plans, plan)
+		}
+	}
+
+	return plans, nil
+}
+
+func (b *Balancer) planRegion(ctx context.Context, dest Destination, layerName string, versions []*lambda.GetLayerVersionByArnOutput) (LayerPlan, error) {
+	pending, listVersions, _, err := b.missingVersions(ctx, dest, layerName, versions)
+	if err != nil {
+		return LayerPlan{}, err
+	}
+
+	plan := LayerPlan{
+		ReadRegion:    b.Config.ReadRegion,
+		ReadRole:      b.Config.ReadRole,
+		ReadRoleChain: b.Config.ReadRoleChain,
+		Region:        dest.Region,
+		LayerName:     layerName,
+		LatestVersion: LatestVersion(listVersions),
+	}
+	if len(versions) > 0 {
+		plan.SourceLayer = awsSDK.ToString(versions[0].LayerArn)
+	}
+	for _, w := range b.Config.WriteRegions {
+		if w.Region == dest.Region {
+			plan.Role = w.Role
+			plan.RoleChain = w.RoleChain
+			plan.StagingBucket = w.StagingBucket
+		}
+	}
+
+	for i, v := range pending {
+		permissions, err := b.permissions(ctx, v)
+		if err != nil {
+			return LayerPlan{}, err
+		}
+		if permissions == nil {
+			permissions = []Permission{}
+		}
+
+		plan.Versions = append(plan.Versions, PlannedVersion{
+			SourceArn:       awsSDK.ToString(v.LayerVersionArn),
+			CodeSha256:      awsSDK.ToString(v.Content.CodeSha256),
+			ExpectedVersion: plan.LatestVersion + int64(i) + 1,
+			Permissions:     permissions,
+		})
+	}
+
+	return plan, nil
+}
+
+// Check compares plan with the current state of the source and destination,
+// every difference is returned as an error wrapping ErrPlanStale. The source
+// versions to copy are returned in plan order when nothing changed.
+func (b *Balancer) Check(ctx context.Context, plan LayerPlan) ([]*lambda.GetLayerVersionByArnOutput, error) {
+	dest, err := b.destination(plan.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+
+	listVersions, err := DiscoverVersions(ctx, dest.Client, plan.LayerName)
+	if err != nil && err != ErrNoVersions {
+		return nil, err
+	}
+	if latest := LatestVersion(listVersions); latest != plan.LatestVersion {
+		errs = append(errs, fmt.Errorf("%w: %s in %s is at version %d, planned at %d", ErrPlanStale, plan.LayerName, plan.Region, latest, plan.LatestVersion))
+	}
+
+	var versions []*lambda.GetLayerVersionByArnOutput
+	for _, p := range plan.Versions {
+		version, err := b.ReadClient.GetLayerVersionByArn(ctx, &lambda.GetLayerVersionByArnInput{
+			Arn: awsSDK.String(p.SourceArn),
+		})
+
+		var notFound *types.ResourceNotFoundException
+		switch {
+		case errors.As(err, &notFound):
+			errs = append(errs, fmt.Errorf("%w: source %s no longer exists", ErrPlanStale, p.SourceArn))
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		if sha := awsSDK.ToString(version.Content.CodeSha256); sha != p.CodeSha256 {
+			errs = append(errs, fmt.Errorf("%w: source %s has CodeSha256 %s, planned with %s", ErrPlanStale, p.SourceArn, sha, p.CodeSha256))
+			continue
+		}
+
+		versions = append(versions, version)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return versions, nil
+}
+
+// Apply publishes the versions of plan in order with their planned
+// permissions, after checking that nothing changed since it was made.
+func (b *Balancer) Apply(ctx context.Context, plan LayerPlan) (result ApplyResult) {
+	log.SetPrefix(fmt.Sprintf("DryRun: %v ", b.Config.DryRun))
+
+	result.LayerName = plan.LayerName
+	result.Region = plan.Region
+
+	versions, err := b.Check(ctx, plan)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	dest, err := b.destination(plan.Region)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	opts := []CopyOption{WithPackageCache(b.Cache), WithDownloader(b.Downloader), WithStaging(dest.Staging), WithReadClient(b.ReadClient)}
+
+	store := b.Packages
+	if store == nil {
+		store = NewPackageStore()
+		defer store.Close()
+	}
+
+	var packages *pipeline
+	if !b.Config.DryRun {
+		packages = startPipeline(ctx, versions, b.Config.Parallelism, store, newCopyOptions(opts...))
+		defer packages.stop()
+	}
+
+	for i, v := range versions {
+		planned := plan.Versions[i]
+
+		// the plan lists every statement, none listed means a private copy
+		permissions := planned.Permissions
+		if permissions == nil {
+			permissions = []Permission{}
+		}
+
+		versionOpts := append([]CopyOption{WithPermissions(permissions)}, opts...)
+		if packages != nil {
+			pkg, err := packages.next(ctx, i)
+			if err != nil {
+				result.Err = err
+				return result
+			}
+			versionOpts = append(versionOpts, WithPackageFile(pkg))
+		}
+
+		out, err := Copy(ctx, dest.Client, plan.LayerName, v, b.Config.DryRun, versionOpts...)
+		if out != nil {
+			result.Published = append(result.Published, awsSDK.ToString(out.LayerVersionArn))
+
+			if out.Version != planned.ExpectedVersion {
+				log.Printf("Version drift: %s published as version %d in %s, planned as %d", planned.SourceArn, out.Version, plan.Region, planned.ExpectedVersion)
+			}
+		}
+		if err != nil {
+			result.Err = err
+			return result
+		}
+	}
+
+	return result
+}
+
+func (b *Balancer) destination(region string) (Destination, error) {
+	for _, dest := range b.Destinations {
+		if dest.Region == region {
+			return dest, nil
+		}
+	}
+
+	return Destination{}, fmt.Errorf("%s is not a write region", region)
+}
@@ -0,0 +1,174 @@
+package layers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestPlan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	// the source has two versions, the destination already has the first one
+	shas := map[int64]string{1: codeSha256("one"), 2: okSha256}
+	source := &FakeClient{
+		ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+			return &lambda.ListLayerVersionsOutput{
+				LayerVersions: []types.LayerVersionsListItem{
+					{Version: 2, LayerVersionArn: awsSDK.String("arn:aws:lambda:us-east-1:012345678912:layer:foo:2")},
+					{Version: 1, LayerVersionArn: awsSDK.String("arn:aws:lambda:us-east-1:012345678912:layer:foo:1")},
+				},
+			}, nil
+		},
+		GetLayerVersionByArnFn: func(ctx context.Context, params *lambda.GetLayerVersionByArnInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionByArnOutput, error) {
+			arn := *params.Arn
+			version, _ := strconv.ParseInt(arn[strings.LastIndex(arn, ":")+1:], 10, 64)
+
+			return &lambda.GetLayerVersionByArnOutput{
+				Version:         version,
+				LayerArn:        awsSDK.String("arn:aws:lambda:us-east-1:012345678912:layer:foo"),
+				LayerVersionArn: params.Arn,
+				Content: &types.LayerVersionContentOutput{
+					CodeSha256: awsSDK.String(shas[version]),
+					Location:   awsSDK.String(server.URL),
+				},
+			}, nil
+		},
+	}
+
+	destVersions := []types.LayerVersionsListItem{
+		{Version: 3, LayerVersionArn: awsSDK.String("arn:aws:lambda:eu-west-1:012345678912:layer:foo:3")},
+	}
+	var published []string
+	dest := &FakeClient{
+		ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+			return &lambda.ListLayerVersionsOutput{LayerVersions: destVersions}, nil
+		},
+		GetLayerVersionByArnFn: func(ctx context.Context, params *lambda.GetLayerVersionByArnInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionByArnOutput, error) {
+			return &lambda.GetLayerVersionByArnOutput{
+				Version:         3,
+				LayerVersionArn: params.Arn,
+				Content:         &types.LayerVersionContentOutput{CodeSha256: awsSDK.String(codeSha256("one"))},
+			}, nil
+		},
+		PublishLayerVersionFn: func(ctx context.Context, params *lambda.PublishLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishLayerVersionOutput, error) {
+			published = append(published, *params.LayerName)
+			return &lambda.PublishLayerVersionOutput{
+				Version:         4,
+				LayerVersionArn: awsSDK.String("arn:aws:lambda:eu-west-1:012345678912:layer:foo:4"),
+			}, nil
+		},
+	}
+
+	var granted []string
+	dest.AddLayerVersionPermissionFn = func(ctx context.Context, params *lambda.AddLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.AddLayerVersionPermissionOutput, error) {
+		granted = append(granted, *params.Principal)
+		return &lambda.AddLayerVersionPermissionOutput{}, nil
+	}
+
+	newBalancer := func(dryRun bool) *layers.Balancer {
+		cfg := config.NewConfig(
+			config.WithReadRegion("us-east-1"),
+			config.WithWriteRegion("eu-west-1", "arn:aws:iam::012345678912:role/write"),
+			config.WithPermissions(config.Permissions{Accounts: []string{"111111111111"}}),
+		)
+		cfg.DryRun = dryRun
+
+		return &layers.Balancer{
+			Config:       cfg,
+			ReadClient:   source,
+			Destinations: []layers.Destination{{Region: "eu-west-1", Client: dest}},
+			Downloader:   layers.NewDownloader(),
+		}
+	}
+
+	plans, err := newBalancer(true).Plan(context.TODO(), "foo")
+	if err != nil {
+		t.Fatalf("expected to plan: %v", err)
+	}
+
+	t.Run("Plan", func(t *testing.T) {
+		if len(plans) != 1 {
+			t.Fatalf("expected a single layer plan, got: %+v", plans)
+		}
+
+		plan := plans[0]
+		if plan.Region != "eu-west-1" || plan.LayerName != "foo" || plan.Role != "arn:aws:iam::012345678912:role/write" || plan.LatestVersion != 3 {
+			t.Errorf("unexpected destination in plan: %+v", plan)
+		}
+
+		if len(plan.Versions) != 1 {
+			t.Fatalf("expected only the missing version to be planned, got: %+v", plan.Versions)
+		}
+
+		v := plan.Versions[0]
+		if v.SourceArn != "arn:aws:lambda:us-east-1:012345678912:layer:foo:2" || v.CodeSha256 != okSha256 || v.ExpectedVersion != 4 {
+			t.Errorf("unexpected planned version: %+v", v)
+		}
+
+		if len(v.Permissions) != 1 || v.Permissions[0].Principal != "111111111111" {
+			t.Errorf("expected the configured permissions in the plan, got: %+v", v.Permissions)
+		}
+
+		if len(published) != 0 {
+			t.Errorf("expected planning not to publish, got: %v", published)
+		}
+	})
+
+	t.Run("Plan align versions", func(t *testing.T) {
+		balancer := newBalancer(true)
+		balancer.Config.AlignVersions = true
+
+		if _, err := balancer.Plan(context.TODO(), "foo"); !errors.Is(err, layers.ErrPlanAlign) {
+			t.Errorf("expected ErrPlanAlign, got: %v", err)
+		}
+	})
+
+	t.Run("Apply destination changed", func(t *testing.T) {
+		destVersions = append(destVersions, types.LayerVersionsListItem{Version: 4, LayerVersionArn: awsSDK.String("arn:aws:lambda:eu-west-1:012345678912:layer:foo:4")})
+		defer func() { destVersions = destVersions[:1] }()
+
+		result := newBalancer(false).Apply(context.TODO(), plans[0])
+		if !errors.Is(result.Err, layers.ErrPlanStale) || len(published) != 0 {
+			t.Errorf("expected a stale plan and nothing published, got: %v, %v", result.Err, published)
+		}
+	})
+
+	t.Run("Apply source changed", func(t *testing.T) {
+		shas[2] = codeSha256("changed")
+		defer func() { shas[2] = okSha256 }()
+
+		result := newBalancer(false).Apply(context.TODO(), plans[0])
+		if !errors.Is(result.Err, layers.ErrPlanStale) || len(published) != 0 {
+			t.Errorf("expected a stale plan and nothing published, got: %v, %v", result.Err, published)
+		}
+	})
+
+	t.Run("Apply", func(t *testing.T) {
+		result := newBalancer(false).Apply(context.TODO(), plans[0])
+		if result.Err != nil {
+			t.Fatalf("expected to apply: %v", result.Err)
+		}
+
+		if len(result.Published) != 1 || result.Published[0] != "arn:aws:lambda:eu-west-1:012345678912:layer:foo:4" {
+			t.Errorf("expected the planned version to be published, got: %v", result.Published)
+		}
+
+		if len(granted) != 1 || granted[0] != "111111111111" {
+			t.Errorf("expected the planned permissions, got: %v", granted)
+		}
+	})
+}
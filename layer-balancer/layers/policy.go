@@ -0,0 +1,283 @@
+package layers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+const (
+	PublicStatementId = "PublicLayerAccess"
+	GetLayerAction    = "lambda:GetLayerVersion"
+)
+
+var (
+	accountPattern = regexp.MustCompile(`^\d{12}$`)
+	rootPattern    = regexp.MustCompile(`^arn:[a-z-]+:iam::(\d{12}):root$`)
+)
+
+// Policy is the resource policy document of a layer version as returned by GetLayerVersionPolicy.
+type Policy struct {
+	Version   string            `json:"Version"`
+	Id        string            `json:"Id"`
+	Statement []PolicyStatement `json:"Statement"`
+}
+
+type PolicyStatement struct {
+	Sid       string                       `json:"Sid"`
+	Effect    string                       `json:"Effect"`
+	Principal PolicyPrincipal              `json:"Principal"`
+	Action    string                       `json:"Action"`
+	Resource  string                       `json:"Resource"`
+	Condition map[string]map[string]string `json:"Condition,omitempty"`
+}
+
+// PolicyPrincipal holds the principals of a statement, a "*" principal is
+// stored as a single "*" entry.
+type PolicyPrincipal []string
+
+func (p *PolicyPrincipal) UnmarshalJSON(data []byte) error {
+	var wildcard string
+	if err := json.Unmarshal(data, &wildcard); err == nil {
+		*p = PolicyPrincipal{wildcard}
+		return nil
+	}
+
+	var principal struct {
+		AWS json.RawMessage `json:"AWS"`
+	}
+	if err := json.Unmarshal(data, &principal); err != nil {
+		return err
+	}
+
+	var single string
+	if err := json.Unmarshal(principal.AWS, &single); err == nil {
+		*p = PolicyPrincipal{single}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(principal.AWS, &many); err != nil {
+		return err
+	}
+	*p = many
+
+	return nil
+}
+
+// Public reports whether any account may use the layer version, without an organization condition.
+func (p *Policy) Public() bool {
+	if p == nil {
+		return false
+	}
+
+	for _, s := range p.Statement {
+		if s.Effect != "Allow" || s.Action != GetLayerAction || s.OrganizationId() != "" {
+			continue
+		}
+
+		for _, principal := range s.Principal {
+			if principal == "*" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (s PolicyStatement) OrganizationId() string {
+	for _, condition := range s.Condition {
+		if org, ok := condition["aws:PrincipalOrgID"]; ok {
+			return org
+		}
+	}
+
+	return ""
+}
+
+// GetPolicy returns the policy of a layer version, or nil when the version has no policy.
+func GetPolicy(ctx context.Context, client LambdaClient, layerName string, version int64) (*Policy, error) {
+	out, err := client.GetLayerVersionPolicy(ctx, &lambda.GetLayerVersionPolicyInput{
+		LayerName:     awsSDK.String(layerName),
+		VersionNumber: awsSDK.Int64(version),
+	})
+
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &Policy{}
+	if err := json.Unmarshal([]byte(awsSDK.ToString(out.Policy)), policy); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// Permission is a single AddLayerVersionPermission statement allowing GetLayerVersion.
+type Permission struct {
+	StatementId    string `json:"statementId"`
+	Principal      string `json:"principal"`
+	OrganizationId string `json:"organizationId,omitempty"`
+}
+
+var PublicPermission = Permission{
+	StatementId: PublicStatementId,
+	Principal:   "*",
+}
+
+// ExplicitPermissions translates a configured permission set into statements.
+func ExplicitPermissions(p config.Permissions) []Permission {
+	permissions := []Permission{}
+	if p.Public {
+		permissions = append(permissions, PublicPermission)
+	}
+
+	if p.OrganizationId != "" {
+		permissions = append(permissions, Permission{
+			StatementId:    "OrganizationAccess",
+			Principal:      "*",
+			OrganizationId: p.OrganizationId,
+		})
+	}
+
+	for _, account := range p.Accounts {
+		permissions = append(permissions, Permission{
+			StatementId: "AccountAccess" + account,
+			Principal:   account,
+		})
+	}
+
+	return permissions
+}
+
+// Permissions translates the statements of a source policy into statements that
+// can be added to another version. Statements that AddLayerVersionPermission
+// can't express are returned as an error.
+func (p *Policy) Permissions() ([]Permission, error) {
+	permissions := []Permission{}
+	if p == nil {
+		return permissions, nil
+	}
+
+	for _, s := range p.Statement {
+		if s.Effect != "Allow" || s.Action != GetLayerAction {
+			return nil, fmt.Errorf("unable to mirror statement %q: only Allow %s is supported", s.Sid, GetLayerAction)
+		}
+
+		for i, principal := range s.Principal {
+			account, err := principalAccount(principal)
+			if err != nil {
+				return nil, fmt.Errorf("unable to mirror statement %q: %w", s.Sid, err)
+			}
+
+			sid := s.Sid
+			if len(s.Principal) > 1 {
+				sid = fmt.Sprintf("%s-%d", s.Sid, i)
+			}
+
+			permissions = append(permissions, Permission{
+				StatementId:    sid,
+				Principal:      account,
+				OrganizationId: s.OrganizationId(),
+			})
+		}
+	}
+
+	return permissions, nil
+}
+
+// principalAccount reduces a policy principal to the form AddLayerVersionPermission
+// accepts, either "*" or an account ID.
+func principalAccount(principal string) (string, error) {
+	if principal == "*" || accountPattern.MatchString(principal) {
+		return principal, nil
+	}
+
+	if match := rootPattern.FindStringSubmatch(principal); match != nil {
+		return match[1], nil
+	}
+
+	return "", fmt.Errorf("unsupported principal %q", principal)
+}
+
+func AddPermissions(ctx context.Context, client LambdaClient, layerName string, version int64, permissions []Permission) error {
+	for _, p := range permissions {
+		input := &lambda.AddLayerVersionPermissionInput{
+			LayerName:     awsSDK.String(layerName),
+			VersionNumber: awsSDK.Int64(version),
+			Action:        awsSDK.String(GetLayerAction),
+			Principal:     awsSDK.String(p.Principal),
+			StatementId:   awsSDK.String(p.StatementId),
+		}
+
+		if p.OrganizationId != "" {
+			input.OrganizationId = awsSDK.String(p.OrganizationId)
+		}
+
+		// a conflict means the statement ID is already in use, most likely by a
+		// previous run, which is only fine when it grants the same access
+		var conflict *types.ResourceConflictException
+		_, err := client.AddLayerVersionPermission(ctx, input)
+		if errors.As(err, &conflict) {
+			err = checkExistingPermission(ctx, client, layerName, version, p)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var ErrPermissionConflict = errors.New("statement ID is in use by a different grant")
+
+// checkExistingPermission reads the policy of version to see whether the
+// statement p.StatementId already grants p.
+func checkExistingPermission(ctx context.Context, client LambdaClient, layerName string, version int64, p Permission) error {
+	policy, err := GetPolicy(ctx, client, layerName, version)
+	if err != nil {
+		return err
+	}
+
+	existing, err := policy.Permissions()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range existing {
+		if e.StatementId != p.StatementId {
+			continue
+		}
+
+		if e.Principal != p.Principal || e.OrganizationId != p.OrganizationId {
+			return fmt.Errorf("%w: %s of %s:%d grants %s, expected %s", ErrPermissionConflict, p.StatementId, layerName, version, grantOf(e), grantOf(p))
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s of %s:%d conflicts but isn't in the policy", ErrPermissionConflict, p.StatementId, layerName, version)
+}
+
+func grantOf(p Permission) string {
+	if p.OrganizationId != "" {
+		return fmt.Sprintf("%s in organization %s", p.Principal, p.OrganizationId)
+	}
+
+	return p.Principal
+}
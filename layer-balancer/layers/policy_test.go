@@ -0,0 +1,253 @@
+package layers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestGetPolicy(t *testing.T) {
+	newClient := func(policy string) *FakeClient {
+		return &FakeClient{
+			GetLayerVersionPolicyFn: func(ctx context.Context, params *lambda.GetLayerVersionPolicyInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionPolicyOutput, error) {
+				if policy == "" {
+					return nil, &types.ResourceNotFoundException{}
+				}
+
+				return &lambda.GetLayerVersionPolicyOutput{
+					Policy: awsSDK.String(policy),
+				}, nil
+			},
+		}
+	}
+
+	t.Run("GetPolicy public", func(t *testing.T) {
+		policy, err := layers.GetPolicy(context.TODO(), newClient(`{"Version":"2012-10-17","Id":"default","Statement":[{"Sid":"PublicLayerAccess","Effect":"Allow","Principal":"*","Action":"lambda:GetLayerVersion","Resource":"arn:aws:lambda:us-east-1:012345678912:layer:foo:1"}]}`), "foo", 1)
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		if !policy.Public() {
+			t.Errorf("expected policy to be public")
+		}
+	})
+
+	t.Run("GetPolicy organization", func(t *testing.T) {
+		policy, err := layers.GetPolicy(context.TODO(), newClient(`{"Version":"2012-10-17","Id":"default","Statement":[{"Sid":"org","Effect":"Allow","Principal":"*","Action":"lambda:GetLayerVersion","Resource":"arn:aws:lambda:us-east-1:012345678912:layer:foo:1","Condition":{"StringEquals":{"aws:PrincipalOrgID":"o-abc"}}},{"Sid":"account","Effect":"Allow","Principal":{"AWS":["arn:aws:iam::223456789012:root"]},"Action":"lambda:GetLayerVersion","Resource":"arn:aws:lambda:us-east-1:012345678912:layer:foo:1"}]}`), "foo", 1)
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		if policy.Public() {
+			t.Errorf("expected policy not to be public")
+		}
+
+		if policy.Statement[0].OrganizationId() != "o-abc" {
+			t.Errorf("wrong organization, got: %s", policy.Statement[0].OrganizationId())
+		}
+
+		if policy.Statement[1].Principal[0] != "arn:aws:iam::223456789012:root" {
+			t.Errorf("wrong principal, got: %v", policy.Statement[1].Principal)
+		}
+	})
+
+	t.Run("GetPolicy not found", func(t *testing.T) {
+		policy, err := layers.GetPolicy(context.TODO(), newClient(""), "foo", 1)
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		if policy != nil || policy.Public() {
+			t.Errorf("expected no policy")
+		}
+	})
+}
+
+func TestPermissions(t *testing.T) {
+	t.Run("Policy Permissions", func(t *testing.T) {
+		policy := &layers.Policy{
+			Statement: []layers.PolicyStatement{
+				{
+					Sid:       "org",
+					Effect:    "Allow",
+					Principal: layers.PolicyPrincipal{"*"},
+					Action:    layers.GetLayerAction,
+					Condition: map[string]map[string]string{
+						"StringEquals": {"aws:PrincipalOrgID": "o-abcdefghij"},
+					},
+				},
+				{
+					Sid:       "accounts",
+					Effect:    "Allow",
+					Principal: layers.PolicyPrincipal{"arn:aws:iam::223456789012:root", "323456789012"},
+					Action:    layers.GetLayerAction,
+				},
+			},
+		}
+
+		permissions, err := policy.Permissions()
+		if err != nil {
+			t.Fatalf("expected no errors: %v", err)
+		}
+
+		expected := []layers.Permission{
+			{StatementId: "org", Principal: "*", OrganizationId: "o-abcdefghij"},
+			{StatementId: "accounts-0", Principal: "223456789012"},
+			{StatementId: "accounts-1", Principal: "323456789012"},
+		}
+
+		if len(permissions) != len(expected) {
+			t.Fatalf("wrong permissions, got: %+v", permissions)
+		}
+
+		for i := range expected {
+			if permissions[i] != expected[i] {
+				t.Errorf("wrong permission, got: %+v", permissions[i])
+			}
+		}
+	})
+
+	t.Run("Policy Permissions unsupported principal", func(t *testing.T) {
+		policy := &layers.Policy{
+			Statement: []layers.PolicyStatement{
+				{
+					Sid:       "role",
+					Effect:    "Allow",
+					Principal: layers.PolicyPrincipal{"arn:aws:iam::223456789012:role/foo"},
+					Action:    layers.GetLayerAction,
+				},
+			},
+		}
+
+		if _, err := policy.Permissions(); err == nil {
+			t.Errorf("expected errors")
+		}
+	})
+
+	t.Run("ExplicitPermissions", func(t *testing.T) {
+		permissions := layers.ExplicitPermissions(config.Permissions{
+			Mirror:         true,
+			OrganizationId: "o-abcdefghij",
+			Accounts:       []string{"223456789012"},
+		})
+
+		if len(permissions) != 2 || permissions[0].OrganizationId != "o-abcdefghij" || permissions[1].Principal != "223456789012" {
+			t.Errorf("wrong permissions, got: %+v", permissions)
+		}
+	})
+}
+
+func TestMirrorPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`OK`))
+	}))
+	defer server.Close()
+
+	policyReads := 0
+	readClient := &FakeClient{
+		GetLayerVersionPolicyFn: func(ctx context.Context, params *lambda.GetLayerVersionPolicyInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionPolicyOutput, error) {
+			policyReads++
+			return &lambda.GetLayerVersionPolicyOutput{
+				Policy: awsSDK.String(`{"Statement":[{"Sid":"account","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::223456789012:root"},"Action":"lambda:GetLayerVersion"}]}`),
+			}, nil
+		},
+	}
+
+	var added []*lambda.AddLayerVersionPermissionInput
+	newClient := func() *FakeClient {
+		return &FakeClient{
+			ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+				return &lambda.ListLayerVersionsOutput{}, nil
+			},
+			PublishLayerVersionFn: func(ctx context.Context, params *lambda.PublishLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishLayerVersionOutput, error) {
+				return &lambda.PublishLayerVersionOutput{
+					Version: 1,
+				}, nil
+			},
+			AddLayerVersionPermissionFn: func(ctx context.Context, params *lambda.AddLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.AddLayerVersionPermissionOutput, error) {
+				added = append(added, params)
+				return nil, nil
+			},
+		}
+	}
+
+	cfg := config.NewConfig(config.WithStartAt(1), config.WithConcurrency(1), config.WithPermissions(config.Permissions{Mirror: true}))
+	cfg.DryRun = false
+
+	balancer := &layers.Balancer{
+		Config:     cfg,
+		ReadClient: readClient,
+		Destinations: []layers.Destination{
+			{Region: "eu-west-1", Client: newClient()},
+			{Region: "eu-west-2", Client: newClient()},
+		},
+	}
+
+	results := balancer.BalanceRegions(context.TODO(), "foo", []*lambda.GetLayerVersionByArnOutput{
+		{
+			Version:         1,
+			LayerArn:        awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo"),
+			LayerVersionArn: awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo:1"),
+			Content: &types.LayerVersionContentOutput{
+				Location: awsSDK.String(server.URL),
+			},
+		},
+	})
+
+	for _, r := range results {
+		if r.Err != nil {
+			t.Fatalf("expected no errors: %v", r.Err)
+		}
+	}
+
+	if policyReads != 1 {
+		t.Errorf("expected the source policy to be read once, got: %d", policyReads)
+	}
+
+	if len(added) != 2 || *added[0].Principal != "223456789012" || *added[0].StatementId != "account" {
+		t.Errorf("expected the source statement on every copy, got: %d", len(added))
+	}
+
+	t.Run("MirrorPolicy failed read is tried again", func(t *testing.T) {
+		policyReads, added = 0, nil
+		readClient.GetLayerVersionPolicyFn = func(ctx context.Context, params *lambda.GetLayerVersionPolicyInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionPolicyOutput, error) {
+			policyReads++
+			if policyReads == 1 {
+				return nil, errors.New("throttled")
+			}
+			return &lambda.GetLayerVersionPolicyOutput{
+				Policy: awsSDK.String(`{"Statement":[{"Sid":"account","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::223456789012:root"},"Action":"lambda:GetLayerVersion"}]}`),
+			}, nil
+		}
+
+		version := &lambda.GetLayerVersionByArnOutput{
+			Version:         2,
+			LayerArn:        awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo"),
+			LayerVersionArn: awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo:2"),
+			Content: &types.LayerVersionContentOutput{
+				Location: awsSDK.String(server.URL),
+			},
+		}
+
+		balancer.Destinations = balancer.Destinations[:1]
+		if results := balancer.BalanceRegions(context.TODO(), "foo", []*lambda.GetLayerVersionByArnOutput{version}); results[0].Err == nil {
+			t.Fatalf("expected the failed policy read to be reported")
+		}
+
+		if results := balancer.BalanceRegions(context.TODO(), "foo", []*lambda.GetLayerVersionByArnOutput{version}); results[0].Err != nil {
+			t.Errorf("expected the policy to be read again, got: %v", results[0].Err)
+		}
+
+		if policyReads != 2 {
+			t.Errorf("expected 2 policy reads, got: %d", policyReads)
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package layers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+var ErrMirrorWithoutSource = errors.New("policies can't be mirrored without a source version, set explicit permissions")
+
+// LocalPackage is a layer package built outside of Lambda, with the metadata
+// to publish it with.
+type LocalPackage struct {
+	LayerName string
+	Path      string
+
+	Description             string
+	LicenseInfo             string
+	CompatibleRuntimes      []types.Runtime
+	CompatibleArchitectures []types.Architecture
+}
+
+// version describes the package the way Lambda describes a published version,
+// so it can be copied and compared like one.
+func (p LocalPackage) version(zip []byte) *lambda.GetLayerVersionByArnOutput {
+	sum := sha256.Sum256(zip)
+
+	return &lambda.GetLayerVersionByArnOutput{
+		LayerArn:                awsSDK.String(p.Path),
+		LayerVersionArn:         awsSDK.String(p.Path),
+		Description:             optionalString(p.Description),
+		LicenseInfo:             optionalString(p.LicenseInfo),
+		CompatibleRuntimes:      p.CompatibleRuntimes,
+		CompatibleArchitectures: p.CompatibleArchitectures,
+		Content: &types.LayerVersionContentOutput{
+			CodeSha256: awsSDK.String(base64.StdEncoding.EncodeToString(sum[:])),
+			CodeSize:   int64(len(zip)),
+		},
+	}
+}
+
+type PublishResult struct {
+	Region          string `json:"region"`
+	LayerVersionArn string `json:"layerVersionArn,omitempty"`
+	Version         int64  `json:"version,omitempty"`
+	// Existing is set when the latest version already held the package.
+	Existing bool  `json:"existing,omitempty"`
+	Err      error `json:"-"`
+}
+
+// Publish publishes pkg as the next version in every destination, running at
+// most Config.Concurrency regions at once. A region whose latest version
+// already has the same content is left alone, so a release can be retried.
+func (b *Balancer) Publish(ctx context.Context, pkg LocalPackage) ([]PublishResult, error) {
+	permissions, err := b.publishPermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	zip, err := os.ReadFile(pkg.Path)
+	if err != nil {
+		return nil, err
+	}
+	version := pkg.version(zip)
+
+	concurrency := b.Config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]PublishResult, len(b.Destinations))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, dest := range b.Destinations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = b.publishRegion(ctx, dest, pkg.LayerName, version, zip, permissions)
+		}()
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+func (b *Balancer) publishRegion(ctx context.Context, dest Destination, layerName string, version *lambda.GetLayerVersionByArnOutput, zip []byte, permissions []Permission) (result PublishResult) {
+	result.Region = dest.Region
+
+	listVersions, err := DiscoverVersions(ctx, dest.Client, layerName)
+	if err != nil && err != ErrNoVersions {
+		result.Err = err
+		return result
+	}
+
+	if latest := LatestVersion(listVersions); latest > 0 {
+		for _, v := range listVersions {
+			if v.Version != latest {
+				continue
+			}
+
+			existing, err := dest.Client.GetLayerVersionByArn(ctx, &lambda.GetLayerVersionByArnInput{
+				Arn: v.LayerVersionArn,
+			})
+			if err != nil {
+				result.Err = err
+				return result
+			}
+
+			if SameContent(version, existing) {
+				log.Printf("Already published: %s", awsSDK.ToString(existing.LayerVersionArn))
+				result.LayerVersionArn = awsSDK.ToString(existing.LayerVersionArn)
+				result.Version = existing.Version
+				result.Existing = true
+				return result
+			}
+		}
+	}
+
+	out, err := Copy(ctx, dest.Client, layerName, version, b.Config.DryRun, WithPackage(zip), WithStaging(dest.Staging), WithPermissions(permissions))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	if out != nil {
+		result.LayerVersionArn = awsSDK.ToString(out.LayerVersionArn)
+		result.Version = out.Version
+	}
+
+	return result
+}
+
+// publishPermissions is the permission logic of Copy without a source policy
+// to mirror.
+func (b *Balancer) publishPermissions() ([]Permission, error) {
+	p := b.Config.Permissions
+	if p.Explicit() {
+		return ExplicitPermissions(p), nil
+	}
+
+	if p.Mirror {
+		return nil, ErrMirrorWithoutSource
+	}
+
+	return []Permission{PublicPermission}, nil
+}
+
+// ArnMap maps the regions that have the package to its version ARN.
+func ArnMap(results []PublishResult) map[string]string {
+	arns := map[string]string{}
+	for _, r := range results {
+		if r.Err == nil && r.LayerVersionArn != "" {
+			arns[r.Region] = r.LayerVersionArn
+		}
+	}
+
+	return arns
+}
+
+func (r PublishResult) String() string {
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("failed: %v", r.Err)
+	case r.Existing:
+		return "already published: " + r.LayerVersionArn
+	case r.LayerVersionArn == "":
+		return "dry run"
+	default:
+		return "published: " + r.LayerVersionArn
+	}
+}
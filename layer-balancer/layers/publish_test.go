@@ -0,0 +1,114 @@
+package layers_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestPublish(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "layer.zip")
+	os.WriteFile(path, []byte("OK"), 0o644)
+
+	pkg := layers.LocalPackage{
+		LayerName:          "foo",
+		Path:               path,
+		Description:        "1.2.3",
+		CompatibleRuntimes: []types.Runtime{types.RuntimePython312},
+	}
+
+	// latest is the content of the latest version in the region, nil for an empty region
+	newClient := func(region string, latest *string, published *[]string) *FakeClient {
+		return &FakeClient{
+			ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+				if latest == nil {
+					return &lambda.ListLayerVersionsOutput{}, nil
+				}
+
+				return &lambda.ListLayerVersionsOutput{
+					LayerVersions: []types.LayerVersionsListItem{
+						{Version: 4, LayerVersionArn: awsSDK.String("arn:aws:lambda:" + region + ":012345678912:layer:foo:4")},
+						{Version: 3, LayerVersionArn: awsSDK.String("arn:aws:lambda:" + region + ":012345678912:layer:foo:3")},
+					},
+				}, nil
+			},
+			GetLayerVersionByArnFn: func(ctx context.Context, params *lambda.GetLayerVersionByArnInput, optFns ...func(*lambda.Options)) (*lambda.GetLayerVersionByArnOutput, error) {
+				return &lambda.GetLayerVersionByArnOutput{
+					Version:            4,
+					LayerVersionArn:    params.Arn,
+					Description:        awsSDK.String("1.2.3"),
+					CompatibleRuntimes: []types.Runtime{types.RuntimePython312},
+					Content: &types.LayerVersionContentOutput{
+						CodeSha256: latest,
+					},
+				}, nil
+			},
+			PublishLayerVersionFn: func(ctx context.Context, params *lambda.PublishLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishLayerVersionOutput, error) {
+				*published = append(*published, region)
+				return &lambda.PublishLayerVersionOutput{
+					Version:         5,
+					LayerVersionArn: awsSDK.String("arn:aws:lambda:" + region + ":012345678912:layer:foo:5"),
+				}, nil
+			},
+			AddLayerVersionPermissionFn: func(ctx context.Context, params *lambda.AddLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.AddLayerVersionPermissionOutput, error) {
+				if *params.Principal != "*" {
+					t.Errorf("expected public permission, got: %s", *params.Principal)
+				}
+				return nil, nil
+			},
+		}
+	}
+
+	t.Run("Publish next version", func(t *testing.T) {
+		var published []string
+
+		cfg := config.NewConfig(config.WithConcurrency(1))
+		cfg.DryRun = false
+		balancer := &layers.Balancer{
+			Config: cfg,
+			Destinations: []layers.Destination{
+				{Region: "eu-west-1", Client: newClient("eu-west-1", nil, &published)},
+				{Region: "eu-west-2", Client: newClient("eu-west-2", awsSDK.String(okSha256), &published)},
+				{Region: "eu-west-3", Client: newClient("eu-west-3", awsSDK.String("other"), &published)},
+			},
+		}
+
+		results, err := balancer.Publish(context.TODO(), pkg)
+		if err != nil {
+			t.Fatalf("expected to succeed: %v", err)
+		}
+
+		sort.Strings(published)
+		if len(published) != 2 || published[0] != "eu-west-1" || published[1] != "eu-west-3" {
+			t.Errorf("expected to publish where the latest version differs, got: %v", published)
+		}
+
+		if !results[1].Existing || results[1].Version != 4 {
+			t.Errorf("expected the existing version to be reported, got: %+v", results[1])
+		}
+
+		arns := layers.ArnMap(results)
+		if len(arns) != 3 || arns["eu-west-1"] != "arn:aws:lambda:eu-west-1:012345678912:layer:foo:5" || arns["eu-west-2"] != "arn:aws:lambda:eu-west-2:012345678912:layer:foo:4" {
+			t.Errorf("wrong ARN map: %v", arns)
+		}
+	})
+
+	t.Run("Publish mirror without source", func(t *testing.T) {
+		balancer := &layers.Balancer{
+			Config: config.NewConfig(config.WithPermissions(config.Permissions{Mirror: true})),
+		}
+
+		if _, err := balancer.Publish(context.TODO(), pkg); !errors.Is(err, layers.ErrMirrorWithoutSource) {
+			t.Errorf("expected mirror to be refused, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package layers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// DefaultStagingThreshold is the largest package published inline with
+// ZipFile, Lambda rejects direct uploads above 50 MB.
+const DefaultStagingThreshold = 50 << 20
+
+const StagingPrefix = "layer-balancer/"
+
+// Staging is a bucket in the write region that packages too large for an
+// inline upload are published from.
+type Staging struct {
+	Client    S3Client
+	Bucket    string
+	Threshold int64
+}
+
+// Use reports whether a package of size has to be published through staging.
+func (s *Staging) Use(size int64) bool {
+	if s == nil || s.Bucket == "" {
+		return false
+	}
+
+	threshold := s.Threshold
+	if threshold <= 0 {
+		threshold = DefaultStagingThreshold
+	}
+
+	return size > threshold
+}
+
+// Stage uploads the size bytes of body and returns the content to publish
+// them from, cleanup deletes the staged object and has to be called once the
+// version is published. The object is named after codeSha256, the base64
+// SHA-256 of body, so publishes of different packages never share a key.
+func (s *Staging) Stage(ctx context.Context, layerName string, codeSha256 string, body io.ReadSeeker, size int64) (content *types.LayerVersionContentInput, cleanup func(), err error) {
+	sum, err := base64.StdEncoding.DecodeString(codeSha256)
+	if err != nil || len(sum) != sha256.Size {
+		return nil, nil, fmt.Errorf("unable to stage package: %q is not a CodeSha256", codeSha256)
+	}
+
+	key := fmt.Sprintf("%s%s/%s.zip", StagingPrefix, layerName, hex.EncodeToString(sum))
+
+	log.Printf("Staging: %d bytes to s3://%s/%s", size, s.Bucket, key)
+
+	_, err = s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        awsSDK.String(s.Bucket),
+		Key:           awsSDK.String(key),
+		Body:          body,
+		ContentLength: awsSDK.Int64(size),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to stage package in %s: %w", s.Bucket, err)
+	}
+
+	cleanup = func() {
+		// the publish context may already be cancelled, the object should still go
+		_, err := s.Client.DeleteObject(context.WithoutCancel(ctx), &s3.DeleteObjectInput{
+			Bucket: awsSDK.String(s.Bucket),
+			Key:    awsSDK.String(key),
+		})
+		if err != nil {
+			log.Printf("Unable to remove staged package s3://%s/%s: %v", s.Bucket, key, err)
+		}
+	}
+
+	return &types.LayerVersionContentInput{
+		S3Bucket: awsSDK.String(s.Bucket),
+		S3Key:    awsSDK.String(key),
+	}, cleanup, nil
+}
+
+type S3Client interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
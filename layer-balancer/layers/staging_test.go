@@ -0,0 +1,127 @@
+package layers_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type FakeS3Client struct {
+	PutObjectFn    func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObjectFn func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+func (c *FakeS3Client) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return c.PutObjectFn(ctx, params, optFns...)
+}
+
+func (c *FakeS3Client) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	return c.DeleteObjectFn(ctx, params, optFns...)
+}
+
+func TestCopyStaging(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`OK`))
+	}))
+	defer server.Close()
+
+	version := &lambda.GetLayerVersionByArnOutput{
+		Version:         3,
+		LayerArn:        awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo"),
+		LayerVersionArn: awsSDK.String("arn:aws:lambda:region:012345678912:layer:foo:3"),
+		Content: &types.LayerVersionContentOutput{
+			Location:   awsSDK.String(server.URL),
+			CodeSha256: awsSDK.String(okSha256),
+			CodeSize:   2,
+		},
+	}
+
+	newClient := func(content **types.LayerVersionContentInput) *FakeClient {
+		return &FakeClient{
+			PublishLayerVersionFn: func(ctx context.Context, params *lambda.PublishLayerVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishLayerVersionOutput, error) {
+				*content = params.Content
+				return &lambda.PublishLayerVersionOutput{Version: 1}, nil
+			},
+			AddLayerVersionPermissionFn: func(ctx context.Context, params *lambda.AddLayerVersionPermissionInput, optFns ...func(*lambda.Options)) (*lambda.AddLayerVersionPermissionOutput, error) {
+				return nil, nil
+			},
+		}
+	}
+
+	t.Run("Copy staged above threshold", func(t *testing.T) {
+		var put, deleted string
+		staging := &layers.Staging{
+			Bucket:    "staging",
+			Threshold: 1,
+			Client: &FakeS3Client{
+				PutObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+					put = *params.Key
+					return &s3.PutObjectOutput{}, nil
+				},
+				DeleteObjectFn: func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+					deleted = *params.Key
+					return &s3.DeleteObjectOutput{}, nil
+				},
+			},
+		}
+
+		var content *types.LayerVersionContentInput
+		_, err := layers.Copy(context.TODO(), newClient(&content), "foo", version, false, layers.WithStaging(staging))
+		if err != nil {
+			t.Fatalf("expected to succeed: %v", err)
+		}
+
+		if content.ZipFile != nil || awsSDK.ToString(content.S3Bucket) != "staging" || awsSDK.ToString(content.S3Key) != put {
+			t.Errorf("expected to publish from the staged object, got: %+v", content)
+		}
+
+		if put != fmt.Sprintf("layer-balancer/foo/%x.zip", sha256.Sum256([]byte("OK"))) || deleted != put {
+			t.Errorf("expected the staged object to be removed, put %q deleted %q", put, deleted)
+		}
+	})
+
+	t.Run("Copy inline below threshold", func(t *testing.T) {
+		staging := &layers.Staging{
+			Bucket: "staging",
+			Client: &FakeS3Client{},
+		}
+
+		var content *types.LayerVersionContentInput
+		_, err := layers.Copy(context.TODO(), newClient(&content), "foo", version, false, layers.WithStaging(staging))
+		if err != nil {
+			t.Fatalf("expected to succeed: %v", err)
+		}
+
+		if string(content.ZipFile) != "OK" || content.S3Bucket != nil {
+			t.Errorf("expected an inline upload, got: %+v", content)
+		}
+	})
+
+	t.Run("Copy staging failure", func(t *testing.T) {
+		staging := &layers.Staging{
+			Bucket:    "staging",
+			Threshold: 1,
+			Client: &FakeS3Client{
+				PutObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+					return nil, errors.New("access denied")
+				},
+			},
+		}
+
+		var content *types.LayerVersionContentInput
+		_, err := layers.Copy(context.TODO(), newClient(&content), "foo", version, false, layers.WithStaging(staging))
+		if err == nil || content != nil {
+			t.Errorf("expected to fail before publishing, got: %v", err)
+		}
+	})
+}
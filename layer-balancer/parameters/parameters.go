@@ -0,0 +1,292 @@
+package parameters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/aws-powertools/actions/layer-balancer/aws"
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+var ErrVersionNotFound = errors.New("layer version not found")
+
+const (
+	// GenericArch is the {arch} of a version compatible with every architecture.
+	GenericArch = "generic"
+	// AllRuntimes is the {runtime} of a version compatible with several runtimes.
+	AllRuntimes = "all"
+)
+
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionNone   Action = "unchanged"
+	// ActionProtected is a release parameter that already points elsewhere, it
+	// is only replaced with Overwrite.
+	ActionProtected Action = "protected"
+)
+
+type Change struct {
+	Region string `json:"region"`
+	Name   string `json:"name"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new"`
+	Action Action `json:"action"`
+}
+
+// Release is a layer whose parameters point at one of its versions.
+type Release struct {
+	LayerName string
+	// LayerVersion is the version to point at, the latest one in each region when 0.
+	LayerVersion   int64
+	PackageVersion string
+	// Latest also points the latest parameter at the version.
+	Latest bool
+}
+
+type RegionResult struct {
+	Region  string
+	Changes []Change
+	Err     error
+}
+
+// Region is a write region with the clients to resolve layers and write parameters in it.
+type Region struct {
+	Region string
+	Lambda layers.LambdaClient
+	SSM    SSMClient
+}
+
+type Publisher struct {
+	Config   *config.Config
+	Regions  []Region
+	Template Template
+	Prefix   string
+	Language string
+
+	// Overwrite replaces release parameters pointing at another version, the
+	// latest parameter is always replaced.
+	Overwrite bool
+
+	checks []aws.Check
+}
+
+func NewPublisher(ctx context.Context, cfg *config.Config, template Template) (*Publisher, error) {
+	if err := cfg.ValidateRoles(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Endpoints.Validate(); err != nil {
+		return nil, err
+	}
+
+	p := &Publisher{
+		Config:   cfg,
+		Template: template,
+		Prefix:   DefaultPrefix,
+	}
+
+	for _, w := range cfg.WriteRegions {
+		clientCfg, err := aws.NewClientConfigWithRoles(ctx, w.Region, cfg.Endpoints, w.Roles(), cfg.RoleOptions)
+		if err != nil {
+			return nil, err
+		}
+		p.checks = append(p.checks, aws.Check{Name: "write", Config: clientCfg})
+
+		p.Regions = append(p.Regions, Region{
+			Region: w.Region,
+			Lambda: clientCfg.LambdaClient(),
+			SSM:    clientCfg.SSMClient(),
+		})
+	}
+
+	return p, nil
+}
+
+// Preflight resolves the identity of every write config, see layers.Balancer.Preflight.
+func (p *Publisher) Preflight(ctx context.Context) ([]aws.CheckResult, error) {
+	return aws.Preflight(ctx, p.checks)
+}
+
+// Publish points the parameters of every release at its layer version in
+// each region, running at most Config.Concurrency regions at once. Changes are
+// only written outside of dry run mode, protected parameters never are.
+func (p *Publisher) Publish(ctx context.Context, releases []Release) []RegionResult {
+	log.SetPrefix(fmt.Sprintf("DryRun: %v ", p.Config.DryRun))
+
+	concurrency := p.Config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]RegionResult, len(p.Regions))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, region := range p.Regions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = p.publishRegion(ctx, region, releases)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (p *Publisher) publishRegion(ctx context.Context, region Region, releases []Release) (result RegionResult) {
+	result.Region = region.Region
+
+	for _, release := range releases {
+		changes, err := p.Changes(ctx, region, release)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+
+		for _, c := range changes {
+			if !p.Config.DryRun && (c.Action == ActionCreate || c.Action == ActionUpdate) {
+				log.Printf("Writing: %s = %s in %s", c.Name, c.New, c.Region)
+
+				if _, err := region.SSM.PutParameter(ctx, &ssm.PutParameterInput{
+					Name:      awsSDK.String(c.Name),
+					Value:     awsSDK.String(c.New),
+					Type:      types.ParameterTypeString,
+					Overwrite: awsSDK.Bool(c.Action == ActionUpdate),
+				}); err != nil {
+					result.Err = fmt.Errorf("%s: %w", c.Name, err)
+					return result
+				}
+			}
+
+			result.Changes = append(result.Changes, c)
+		}
+	}
+
+	return result
+}
+
+// Changes compares the parameters of release in region with the ARN of its
+// layer version there, nothing is written.
+func (p *Publisher) Changes(ctx context.Context, region Region, release Release) ([]Change, error) {
+	listVersions, err := layers.DiscoverVersions(ctx, region.Lambda, release.LayerName)
+	if err != nil {
+		return nil, fmt.Errorf("%s in %s: %w", release.LayerName, region.Region, err)
+	}
+
+	target, err := findVersion(listVersions, release.LayerVersion)
+	if err != nil {
+		return nil, fmt.Errorf("%s in %s: %w", release.LayerName, region.Region, err)
+	}
+	arn := awsSDK.ToString(target.LayerVersionArn)
+
+	var versions []string
+	if release.PackageVersion != "" {
+		versions = append(versions, release.PackageVersion)
+	}
+	if release.Latest {
+		versions = append(versions, Latest)
+	}
+
+	var changes []Change
+	for _, version := range versions {
+		name := p.Template.Name(Fields{
+			Prefix:   p.Prefix,
+			Language: p.Language,
+			Arch:     Arch(target),
+			Runtime:  Runtime(target),
+			Version:  version,
+		})
+
+		old, err := getParameter(ctx, region.SSM, name)
+		if err != nil {
+			return nil, fmt.Errorf("%s in %s: %w", name, region.Region, err)
+		}
+
+		c := Change{Region: region.Region, Name: name, Old: old, New: arn}
+		switch {
+		case old == "":
+			c.Action = ActionCreate
+		case old == arn:
+			c.Action = ActionNone
+		case version == Latest || p.Overwrite:
+			c.Action = ActionUpdate
+		default:
+			c.Action = ActionProtected
+		}
+
+		changes = append(changes, c)
+	}
+
+	return changes, nil
+}
+
+// Arch is the {arch} of a version, its only compatible architecture or GenericArch.
+func Arch(v lambdaTypes.LayerVersionsListItem) string {
+	if len(v.CompatibleArchitectures) == 1 {
+		return string(v.CompatibleArchitectures[0])
+	}
+
+	return GenericArch
+}
+
+// Runtime is the {runtime} of a version, its only compatible runtime or AllRuntimes.
+func Runtime(v lambdaTypes.LayerVersionsListItem) string {
+	if len(v.CompatibleRuntimes) == 1 {
+		return string(v.CompatibleRuntimes[0])
+	}
+
+	return AllRuntimes
+}
+
+func findVersion(listVersions []lambdaTypes.LayerVersionsListItem, version int64) (lambdaTypes.LayerVersionsListItem, error) {
+	if version == 0 {
+		version = layers.LatestVersion(listVersions)
+	}
+
+	for _, v := range listVersions {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+
+	return lambdaTypes.LayerVersionsListItem{}, fmt.Errorf("%w: %d", ErrVersionNotFound, version)
+}
+
+// getParameter returns the value of name, or an empty string when it doesn't exist.
+func getParameter(ctx context.Context, client SSMClient, name string) (string, error) {
+	out, err := client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name: awsSDK.String(name),
+	})
+
+	var notFound *types.ParameterNotFound
+	if errors.As(err, &notFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return awsSDK.ToString(out.Parameter.Value), nil
+}
+
+type SSMClient interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+	PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error)
+	GetParametersByPath(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error)
+}
@@ -0,0 +1,207 @@
+package parameters_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+	"github.com/aws-powertools/actions/layer-balancer/parameters"
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+type FakeSSMClient struct {
+	GetParameterFn func(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+	PutParameterFn func(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error)
+
+	GetParametersByPathFn func(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error)
+}
+
+func (c *FakeSSMClient) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	return c.GetParameterFn(ctx, params, optFns...)
+}
+
+func (c *FakeSSMClient) PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+	return c.PutParameterFn(ctx, params, optFns...)
+}
+
+func (c *FakeSSMClient) GetParametersByPath(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error) {
+	return c.GetParametersByPathFn(ctx, params, optFns...)
+}
+
+// FakeLambdaClient only lists layer versions, which is all parameters need.
+type FakeLambdaClient struct {
+	layers.LambdaClient
+
+	ListLayerVersionsFn func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error)
+}
+
+func (c *FakeLambdaClient) ListLayerVersions(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+	return c.ListLayerVersionsFn(ctx, params, optFns...)
+}
+
+// newSSM fakes a parameter store holding store, writes are added to it.
+func newSSM(store map[string]string) *FakeSSMClient {
+	return &FakeSSMClient{
+		GetParameterFn: func(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+			value, ok := store[*params.Name]
+			if !ok {
+				return nil, &types.ParameterNotFound{}
+			}
+
+			return &ssm.GetParameterOutput{
+				Parameter: &types.Parameter{Name: params.Name, Value: awsSDK.String(value)},
+			}, nil
+		},
+		PutParameterFn: func(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+			if _, ok := store[*params.Name]; ok && !awsSDK.ToBool(params.Overwrite) {
+				return nil, &types.ParameterAlreadyExists{}
+			}
+
+			store[*params.Name] = *params.Value
+			return &ssm.PutParameterOutput{}, nil
+		},
+		GetParametersByPathFn: func(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error) {
+			out := &ssm.GetParametersByPathOutput{}
+			for name, value := range store {
+				if strings.HasPrefix(name, *params.Path+"/") {
+					out.Parameters = append(out.Parameters, types.Parameter{Name: awsSDK.String(name), Value: awsSDK.String(value)})
+				}
+			}
+
+			return out, nil
+		},
+	}
+}
+
+func newLambda(region string) *FakeLambdaClient {
+	return &FakeLambdaClient{
+		ListLayerVersionsFn: func(ctx context.Context, params *lambda.ListLayerVersionsInput, optFns ...func(*lambda.Options)) (*lambda.ListLayerVersionsOutput, error) {
+			var versions []lambdaTypes.LayerVersionsListItem
+			for _, v := range []string{"7", "6"} {
+				versions = append(versions, lambdaTypes.LayerVersionsListItem{
+					Version:                 int64(v[0] - '0'),
+					LayerVersionArn:         awsSDK.String("arn:aws:lambda:" + region + ":017000801446:layer:" + *params.LayerName + ":" + v),
+					CompatibleRuntimes:      []lambdaTypes.Runtime{lambdaTypes.RuntimePython312},
+					CompatibleArchitectures: []lambdaTypes.Architecture{lambdaTypes.ArchitectureArm64},
+				})
+			}
+
+			return &lambda.ListLayerVersionsOutput{LayerVersions: versions}, nil
+		},
+	}
+}
+
+func TestPublish(t *testing.T) {
+	template, _ := parameters.NewTemplate(parameters.DefaultTemplate)
+	release := parameters.Release{
+		LayerName:      "AWSLambdaPowertoolsPythonV3-python312-arm64",
+		PackageVersion: "3.4.0",
+		Latest:         true,
+	}
+
+	newPublisher := func(dryRun bool, store map[string]string) *parameters.Publisher {
+		cfg := config.NewConfig()
+		cfg.DryRun = dryRun
+
+		return &parameters.Publisher{
+			Config:   cfg,
+			Template: template,
+			Prefix:   parameters.DefaultPrefix,
+			Language: "python",
+			Regions: []parameters.Region{
+				{Region: "eu-west-1", Lambda: newLambda("eu-west-1"), SSM: newSSM(store)},
+			},
+		}
+	}
+
+	versionName := "/aws/service/powertools/python/arm64/python3.12/3.4.0"
+	latestName := "/aws/service/powertools/python/arm64/python3.12/latest"
+	arn := "arn:aws:lambda:eu-west-1:017000801446:layer:AWSLambdaPowertoolsPythonV3-python312-arm64:7"
+
+	t.Run("Publish dry run", func(t *testing.T) {
+		store := map[string]string{}
+		results := newPublisher(true, store).Publish(context.TODO(), []parameters.Release{release})
+
+		if results[0].Err != nil {
+			t.Fatalf("expected no errors: %v", results[0].Err)
+		}
+
+		changes := results[0].Changes
+		if len(changes) != 2 || changes[0].Name != versionName || changes[1].Name != latestName {
+			t.Fatalf("expected the release and latest parameters, got: %+v", changes)
+		}
+
+		for _, c := range changes {
+			if c.Action != parameters.ActionCreate || c.New != arn {
+				t.Errorf("expected the latest version to be created, got: %+v", c)
+			}
+		}
+
+		if len(store) != 0 {
+			t.Errorf("expected a dry run not to write, got: %v", store)
+		}
+	})
+
+	t.Run("Publish", func(t *testing.T) {
+		store := map[string]string{latestName: "arn:aws:lambda:eu-west-1:017000801446:layer:AWSLambdaPowertoolsPythonV3-python312-arm64:6"}
+		results := newPublisher(false, store).Publish(context.TODO(), []parameters.Release{release})
+
+		if results[0].Err != nil {
+			t.Fatalf("expected no errors: %v", results[0].Err)
+		}
+
+		if store[versionName] != arn || store[latestName] != arn {
+			t.Errorf("expected both parameters to point at the version, got: %v", store)
+		}
+
+		if results[0].Changes[1].Action != parameters.ActionUpdate {
+			t.Errorf("expected latest to be updated, got: %+v", results[0].Changes[1])
+		}
+	})
+
+	t.Run("Publish protected", func(t *testing.T) {
+		old := "arn:aws:lambda:eu-west-1:017000801446:layer:AWSLambdaPowertoolsPythonV3-python312-arm64:6"
+		store := map[string]string{versionName: old}
+
+		publisher := newPublisher(false, store)
+		results := publisher.Publish(context.TODO(), []parameters.Release{release})
+
+		if results[0].Err != nil {
+			t.Fatalf("expected no errors: %v", results[0].Err)
+		}
+
+		if c := results[0].Changes[0]; c.Action != parameters.ActionProtected || store[versionName] != old {
+			t.Errorf("expected the release parameter to be left alone, got: %+v, %v", c, store)
+		}
+
+		publisher.Overwrite = true
+		publisher.Publish(context.TODO(), []parameters.Release{release})
+
+		if store[versionName] != arn {
+			t.Errorf("expected -overwrite to replace the release parameter, got: %v", store)
+		}
+	})
+
+	t.Run("Publish layer version", func(t *testing.T) {
+		pinned := release
+		pinned.LayerVersion = 6
+
+		results := newPublisher(true, map[string]string{}).Publish(context.TODO(), []parameters.Release{pinned})
+		if c := results[0].Changes[0]; c.New != "arn:aws:lambda:eu-west-1:017000801446:layer:AWSLambdaPowertoolsPythonV3-python312-arm64:6" {
+			t.Errorf("expected the pinned version, got: %+v", c)
+		}
+
+		pinned.LayerVersion = 9
+		results = newPublisher(true, map[string]string{}).Publish(context.TODO(), []parameters.Release{pinned})
+		if !errors.Is(results[0].Err, parameters.ErrVersionNotFound) {
+			t.Errorf("expected ErrVersionNotFound, got: %v", results[0].Err)
+		}
+	})
+}
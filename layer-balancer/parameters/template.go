@@ -0,0 +1,74 @@
+package parameters
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	DefaultTemplate = "{prefix}/{language}/{arch}/{runtime}/{version}"
+	DefaultPrefix   = "/aws/service/powertools"
+
+	// Latest is the version of the parameter that follows the newest release.
+	Latest = "latest"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+var placeholders = []string{"prefix", "language", "arch", "runtime", "version"}
+
+// Fields are the values a Template is filled in with.
+type Fields struct {
+	Prefix   string
+	Language string
+	Arch     string
+	Runtime  string
+	// Version is the package version of the release, or Latest.
+	Version string
+}
+
+// Template builds parameter names from {prefix}, {language}, {arch},
+// {runtime} and {version} placeholders.
+type Template struct {
+	raw string
+}
+
+func NewTemplate(raw string) (Template, error) {
+	for _, match := range placeholderPattern.FindAllStringSubmatch(raw, -1) {
+		if !slices.Contains(placeholders, match[1]) {
+			return Template{}, fmt.Errorf("template %q: unknown placeholder %s, use one of {%s}", raw, match[0], strings.Join(placeholders, "}, {"))
+		}
+	}
+
+	// without it the latest parameter and every release would share a name
+	if !strings.Contains(raw, "{version}") {
+		return Template{}, fmt.Errorf("template %q has no {version} placeholder", raw)
+	}
+
+	return Template{raw: raw}, nil
+}
+
+// Name fills in the template, repeated slashes e.g. from a prefix ending in
+// one are collapsed.
+func (t Template) Name(f Fields) string {
+	name := strings.NewReplacer(
+		"{prefix}", f.Prefix,
+		"{language}", f.Language,
+		"{arch}", f.Arch,
+		"{runtime}", f.Runtime,
+		"{version}", f.Version,
+	).Replace(t.raw)
+
+	if strings.HasPrefix(name, "/") {
+		name = path.Clean(name)
+	}
+
+	return name
+}
+
+func (t Template) String() string {
+	return t.raw
+}
@@ -0,0 +1,40 @@
+package parameters_test
+
+import (
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/parameters"
+)
+
+func TestTemplate(t *testing.T) {
+	fields := parameters.Fields{
+		Prefix:   "/aws/service/powertools/",
+		Language: "python",
+		Arch:     "arm64",
+		Runtime:  "python3.12",
+		Version:  "3.4.0",
+	}
+
+	t.Run("Template default", func(t *testing.T) {
+		template, err := parameters.NewTemplate(parameters.DefaultTemplate)
+		if err != nil {
+			t.Fatalf("expected the default template to be valid: %v", err)
+		}
+
+		if name := template.Name(fields); name != "/aws/service/powertools/python/arm64/python3.12/3.4.0" {
+			t.Errorf("unexpected name: %s", name)
+		}
+	})
+
+	t.Run("Template unknown placeholder", func(t *testing.T) {
+		if _, err := parameters.NewTemplate("{prefix}/{lang}/{version}"); err == nil {
+			t.Error("expected an unknown placeholder to fail")
+		}
+	})
+
+	t.Run("Template without version", func(t *testing.T) {
+		if _, err := parameters.NewTemplate("{prefix}/{language}"); err == nil {
+			t.Error("expected a template without {version} to fail")
+		}
+	})
+}
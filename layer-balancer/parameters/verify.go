@@ -0,0 +1,208 @@
+package parameters
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws-powertools/actions/layer-balancer/layers"
+
+	awsSDK "github.com/aws/aws-sdk-go-v2/aws"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// Finding is a parameter that doesn't point where it should. Region is empty
+// for findings across regions.
+type Finding struct {
+	Region  string `json:"region,omitempty"`
+	Name    string `json:"name"`
+	Value   string `json:"value,omitempty"`
+	Problem string `json:"problem"`
+}
+
+// regionParameters are the names of the parameters found under the prefix in
+// one region, and the layer version of the valid release parameters.
+type regionParameters struct {
+	region   string
+	names    map[string]bool
+	versions map[string]int64
+	findings []Finding
+	err      error
+}
+
+// Verify walks the parameters under Prefix in every region and checks that
+// each one points at an existing layer version in the same region, that the
+// latest parameters point at the highest version and that a release
+// parameter has the same layer version number in every region. Nothing is written.
+func (p *Publisher) Verify(ctx context.Context) ([]Finding, error) {
+	concurrency := p.Config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]regionParameters, len(p.Regions))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, region := range p.Regions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = p.verifyRegion(ctx, region)
+		}()
+	}
+	wg.Wait()
+
+	var findings []Finding
+	for _, r := range results {
+		if r.err != nil {
+			return nil, fmt.Errorf("%s: %w", r.region, r.err)
+		}
+
+		findings = append(findings, r.findings...)
+	}
+	findings = append(findings, compareRegions(results)...)
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Name != findings[j].Name {
+			return findings[i].Name < findings[j].Name
+		}
+
+		return findings[i].Region < findings[j].Region
+	})
+
+	return findings, nil
+}
+
+func (p *Publisher) verifyRegion(ctx context.Context, region Region) (result regionParameters) {
+	result.region = region.Region
+	result.names = map[string]bool{}
+	result.versions = map[string]int64{}
+
+	prefix := p.Prefix
+	if strings.HasPrefix(prefix, "/") {
+		prefix = path.Clean(prefix)
+	}
+
+	params, err := getParametersByPath(ctx, region.SSM, prefix)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	// every layer is only listed once however many parameters point at it
+	listed := map[string][]lambdaTypes.LayerVersionsListItem{}
+	listErrs := map[string]error{}
+
+	for name, value := range params {
+		result.names[name] = true
+
+		finding := func(problem string, args ...any) {
+			result.findings = append(result.findings, Finding{Region: region.Region, Name: name, Value: value, Problem: fmt.Sprintf(problem, args...)})
+		}
+
+		arn, err := layers.ParseLayerArn(value)
+		if err != nil || arn.Version == 0 {
+			finding("not a layer version ARN")
+			continue
+		}
+
+		if arn.Region != region.Region {
+			finding("points at %s", arn.Region)
+			continue
+		}
+
+		layer := arn.Unversioned()
+		if _, ok := listErrs[layer]; !ok {
+			listed[layer], listErrs[layer] = layers.DiscoverVersions(ctx, region.Lambda, layer)
+		}
+		if err := listErrs[layer]; err != nil {
+			finding("unable to list layer versions: %v", err)
+			continue
+		}
+
+		listVersions := listed[layer]
+		if !slices.ContainsFunc(listVersions, func(v lambdaTypes.LayerVersionsListItem) bool { return v.Version == arn.Version }) {
+			finding("layer version %d does not exist", arn.Version)
+			continue
+		}
+
+		if path.Base(name) == Latest {
+			if highest := layers.LatestVersion(listVersions); arn.Version != highest {
+				finding("latest points at version %d, the highest is %d", arn.Version, highest)
+			}
+			continue
+		}
+
+		result.versions[name] = arn.Version
+	}
+
+	return result
+}
+
+// compareRegions reports release parameters whose layer version number
+// differs between regions, or that are missing from some regions.
+func compareRegions(results []regionParameters) []Finding {
+	present := map[string]int{}
+	for _, r := range results {
+		for name := range r.names {
+			present[name]++
+		}
+	}
+
+	var findings []Finding
+	for name, count := range present {
+		var seen []string
+		versions := map[int64]bool{}
+		for _, r := range results {
+			if !r.names[name] {
+				findings = append(findings, Finding{Region: r.region, Name: name, Problem: fmt.Sprintf("missing, present in %d other regions", count)})
+			}
+
+			if version, ok := r.versions[name]; ok {
+				seen = append(seen, fmt.Sprintf("%s=%d", r.region, version))
+				versions[version] = true
+			}
+		}
+
+		if len(versions) > 1 {
+			findings = append(findings, Finding{Name: name, Problem: "layer versions differ: " + strings.Join(seen, ", ")})
+		}
+	}
+
+	return findings
+}
+
+// getParametersByPath returns the name and value of every parameter under prefix.
+func getParametersByPath(ctx context.Context, client SSMClient, prefix string) (map[string]string, error) {
+	params := map[string]string{}
+
+	input := &ssm.GetParametersByPathInput{
+		Path:      awsSDK.String(prefix),
+		Recursive: awsSDK.Bool(true),
+	}
+	for {
+		out, err := client.GetParametersByPath(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, param := range out.Parameters {
+			params[awsSDK.ToString(param.Name)] = awsSDK.ToString(param.Value)
+		}
+
+		if out.NextToken == nil {
+			return params, nil
+		}
+		input.NextToken = out.NextToken
+	}
+}
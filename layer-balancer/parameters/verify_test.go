@@ -0,0 +1,80 @@
+package parameters_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws-powertools/actions/layer-balancer/config"
+	"github.com/aws-powertools/actions/layer-balancer/parameters"
+)
+
+func TestVerify(t *testing.T) {
+	const prefix = "/aws/service/powertools/python/arm64/python3.12/"
+	arn := func(region string, version string) string {
+		return "arn:aws:lambda:" + region + ":017000801446:layer:AWSLambdaPowertoolsPythonV3-python312-arm64:" + version
+	}
+
+	newPublisher := func(eu map[string]string, us map[string]string) *parameters.Publisher {
+		return &parameters.Publisher{
+			Config: config.NewConfig(),
+			Prefix: parameters.DefaultPrefix,
+			Regions: []parameters.Region{
+				{Region: "eu-west-1", Lambda: newLambda("eu-west-1"), SSM: newSSM(eu)},
+				{Region: "us-east-1", Lambda: newLambda("us-east-1"), SSM: newSSM(us)},
+			},
+		}
+	}
+
+	t.Run("Verify consistent", func(t *testing.T) {
+		findings, err := newPublisher(
+			map[string]string{prefix + "3.4.0": arn("eu-west-1", "7"), prefix + "latest": arn("eu-west-1", "7")},
+			map[string]string{prefix + "3.4.0": arn("us-east-1", "7"), prefix + "latest": arn("us-east-1", "7")},
+		).Verify(context.TODO())
+		if err != nil {
+			t.Fatalf("expected to verify: %v", err)
+		}
+
+		if len(findings) != 0 {
+			t.Errorf("expected no findings, got: %+v", findings)
+		}
+	})
+
+	t.Run("Verify inconsistent", func(t *testing.T) {
+		findings, err := newPublisher(
+			map[string]string{
+				prefix + "3.2.0":  arn("us-east-1", "6"),
+				prefix + "3.3.0":  arn("eu-west-1", "5"),
+				prefix + "3.4.0":  arn("eu-west-1", "7"),
+				prefix + "latest": arn("eu-west-1", "6"),
+			},
+			map[string]string{
+				prefix + "3.2.0":  arn("us-east-1", "6"),
+				prefix + "3.4.0":  arn("us-east-1", "6"),
+				prefix + "latest": arn("us-east-1", "7"),
+			},
+		).Verify(context.TODO())
+		if err != nil {
+			t.Fatalf("expected to verify: %v", err)
+		}
+
+		expected := []parameters.Finding{
+			{Region: "eu-west-1", Name: prefix + "3.2.0", Problem: "points at us-east-1"},
+			{Region: "eu-west-1", Name: prefix + "3.3.0", Problem: "layer version 5 does not exist"},
+			{Region: "us-east-1", Name: prefix + "3.3.0", Problem: "missing, present in 1 other regions"},
+			{Name: prefix + "3.4.0", Problem: "layer versions differ: eu-west-1=7, us-east-1=6"},
+			{Region: "eu-west-1", Name: prefix + "latest", Problem: "latest points at version 6, the highest is 7"},
+		}
+
+		if len(findings) != len(expected) {
+			t.Fatalf("expected %d findings, got: %+v", len(expected), findings)
+		}
+
+		for i, f := range findings {
+			e := expected[i]
+			if f.Region != e.Region || f.Name != e.Name || !strings.HasPrefix(f.Problem, e.Problem) {
+				t.Errorf("expected %+v, got: %+v", e, f)
+			}
+		}
+	})
+}